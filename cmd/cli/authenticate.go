@@ -2,13 +2,26 @@ package cli
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ondrovic/bambulab-authenticator/internal/auth"
 	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/history"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/sinks"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
 
+	sCli "github.com/ondrovic/common/utils/cli"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var (
@@ -23,12 +36,27 @@ var (
 func initAuthenticateFlags() {
 
 	authenticateCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", consts.EMPTY_STRING, "Output path of the authentication info")
-	authenticateCmd.Flags().StringVarP(&Options.UserAccount, "user-account", "u", consts.EMPTY_STRING, "User account")
-	authenticateCmd.Flags().StringVarP(&Options.UserPassword, "user-password", "p", consts.EMPTY_STRING, "User account password")
+	authenticateCmd.Flags().StringVarP(&Options.UserAccount, "user-account", "u", consts.EMPTY_STRING, "User account: email address or phone number")
 	authenticateCmd.Flags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region")
 
 	markAllFlagsRequired(authenticateCmd)
 
+	// Optional flags are registered after the required ones are marked
+	authenticateCmd.Flags().StringVar(&Options.Method, "method", auth.MethodPassword, "Login method: "+strings.Join(auth.Methods, ", ")+" (email-code logs in with an emailed code alone)")
+	authenticateCmd.Flags().StringVarP(&Options.UserPassword, "user-password", "p", consts.EMPTY_STRING, "User account password (prompted for when no other source is given)")
+	authenticateCmd.Flags().StringVar(&Options.PasswordCommand, "password-command", consts.EMPTY_STRING, "Command whose first line of output is the password, e.g. \"pass show bambulab\"")
+	authenticateCmd.Flags().StringVar(&Options.CredentialHelper, "credential-helper", consts.EMPTY_STRING, "git-style credential helper command used to get, store and erase the password")
+	authenticateCmd.Flags().StringVarP(&Options.Code, "code", "c", consts.EMPTY_STRING, "Email code or one-time password, for non-interactive logins")
+	authenticateCmd.Flags().StringVar(&Options.CodeCommand, "code-command", consts.EMPTY_STRING, "Command whose first line of output is the email code or one-time password")
+	authenticateCmd.Flags().IntVar(&Options.CodeAttempts, "code-attempts", 3, "How many times a rejected code is asked for again when typed at the prompt")
+	authenticateCmd.Flags().DurationVar(&Options.ResendCooldown, "resend-cooldown", time.Minute, "Minimum wait between sending codes when a resend is requested at the prompt")
+	authenticateCmd.Flags().StringVar(&Options.TOTPSecret, "totp-secret", consts.EMPTY_STRING, "Base32 2FA secret used to generate the one-time password")
+	authenticateCmd.Flags().IntVar(&Options.HistorySize, "history-size", history.DefaultSize, "How many previous auth files to keep in the history directory, 0 to keep none")
+	authenticateCmd.Flags().BoolVar(&Options.SaveCookies, "save-cookies", false, "Save the session cookies to cookies.json next to the auth file and reuse them on the next login")
+	authenticateCmd.Flags().StringVar(&Options.StateDir, "state-dir", auth.DefaultStateDir(), "Directory remembering failed attempts and cooldowns across runs, empty to disable")
+	authenticateCmd.Flags().BoolVar(&Options.Force, "force", false, "Attempt the login even when earlier failures or a server cooldown say to wait")
+	authenticateCmd.Flags().StringArrayVar(&Options.Sinks, "sink", nil, "Also deliver the tokens to a sink (repeatable): stdout, file:<dir>, webhook:<url>, mqtt(s)://host/topic, exec:<command>")
+
 	viper.BindPFlags(authenticateCmd.Flags())
 }
 
@@ -36,16 +64,81 @@ func markAllFlagsRequired(cmd *cobra.Command) {
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
 		err := cmd.MarkFlagRequired(flag.Name)
 		if err != nil {
-			fmt.Printf("error setting flag: %s required: %v", flag.Name, err)
+			slog.Error("error setting flag required", "flag", flag.Name, "error", err)
 		}
 	})
 }
 
 func runAuthenticate(cmd *cobra.Command, args []string) error {
 
+	if Options.Method == auth.MethodEmailCode && (Options.UserPassword != consts.EMPTY_STRING || Options.PasswordCommand != consts.EMPTY_STRING || Options.CredentialHelper != consts.EMPTY_STRING) {
+		slog.Warn("the password is not used with the email-code login method")
+	}
+
+	// Parse the sinks up front so a typo fails before any login attempt
+	tokenSinks, err := sinks.ParseAll(Options.Sinks)
+	if err != nil {
+		return err
+	}
+	if isJSONOutput() && slices.Contains(Options.Sinks, "stdout") {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("the stdout sink cannot be combined with --output json"))
+	}
+
+	// Clearing only makes sense for a person about to answer the login prompts, not when stdout is captured
+	if !Options.NonInteractive && !isJSONOutput() && term.IsTerminal(int(os.Stdout.Fd())) {
+		if err := sCli.ClearTerminalScreen(runtime.GOOS); err != nil {
+			return err
+		}
+	}
+
 	if err := auth.Login(&Options); err != nil {
+		if types.ErrorCode(err) == types.ErrCodeUnknown {
+			return types.NewCodedError(types.ErrCodeLoginFailed, err)
+		}
 		return err
 	}
 
-	return nil
+	authFilePath := utils.AuthFilePath(Options.OutputPath)
+	tokens, err := utils.LoadLoginResponseFromFile(Options.OutputPath)
+	if err != nil {
+		return err
+	}
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Message = fmt.Sprintf("Auth data saved to %s", authFilePath)
+	result.Account = Options.UserAccount
+	result.Region = Options.UserRegion
+	result.Files = []string{authFilePath}
+
+	if len(tokenSinks) == 0 {
+		return writeResult(cmd, result)
+	}
+
+	reports := sinks.Deliver(tokenSinks, *tokens)
+	for _, report := range reports {
+		if report.Path != "" {
+			result.Files = append(result.Files, report.Path)
+		}
+		if report.Error != "" {
+			slog.Error("failed to deliver tokens", "sink", report.Sink, "error", report.Error)
+		} else {
+			slog.Info("delivered tokens", "sink", report.Sink)
+		}
+	}
+	result.Data = map[string]any{"sinks": reports}
+
+	if failed := sinks.Failed(reports); len(failed) > 0 {
+		// The auth file is saved; report the partial failure with the per-sink outcome
+		result.Success = false
+		result.Error = &output.ResultError{
+			Code:    types.ErrCodeSinkFailed,
+			Message: fmt.Sprintf("%d of %d sinks failed", len(failed), len(reports)),
+		}
+		if err := writeResult(cmd, result); err != nil {
+			return err
+		}
+		return &reportedError{types.NewCodedError(types.ErrCodeSinkFailed, fmt.Errorf("%s", result.Error.Message))}
+	}
+
+	return writeResult(cmd, result)
 }
@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/monitor"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// checkService names the service in the plugin output line.
+const checkService = "BAMBU"
+
+var (
+	checkOptions = types.CheckFlags{}
+	checkCmd     = &cobra.Command{
+		Use:   "check",
+		Short: "Check the saved session as a Nagios/Icinga plugin",
+		Long: `Check the time left on the access and refresh tokens and whether the profile endpoint
+accepts the session, following the monitoring plugin conventions: one status line with
+perfdata on stdout and exit code 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN).
+
+With --prometheus the results are also written as metrics for the node_exporter
+textfile collector, e.g.
+
+  bambulab-authenticator check --prometheus /var/lib/node_exporter/textfile/bambu.prom
+
+The session is used read-only: a token refreshed for the profile check is not saved.`,
+		Args: cobra.ExactArgs(0),
+		RunE: runCheck,
+	}
+)
+
+func initCheckFlags() {
+
+	checkCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	checkCmd.Flags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region (defaults to the region saved with the token)")
+	checkCmd.Flags().DurationVarP(&checkOptions.Warning, "warning", "w", 7*24*time.Hour, "WARNING when the access token expires within this time")
+	checkCmd.Flags().DurationVarP(&checkOptions.Critical, "critical", "c", 24*time.Hour, "CRITICAL when the access token expires within this time")
+	checkCmd.Flags().DurationVar(&checkOptions.RefreshWarning, "refresh-warning", 14*24*time.Hour, "WARNING when the refresh token expires within this time")
+	checkCmd.Flags().DurationVar(&checkOptions.RefreshCritical, "refresh-critical", 3*24*time.Hour, "CRITICAL when the refresh token expires within this time")
+	checkCmd.Flags().BoolVar(&checkOptions.SkipProfile, "skip-profile", false, "Do not call the profile endpoint, only check the saved expiry times")
+	checkCmd.Flags().StringVar(&checkOptions.Prometheus, "prometheus", consts.EMPTY_STRING, "Also write the results to this node_exporter textfile (*.prom)")
+
+	// A mistyped flag is not a WARNING, which is what the default exit code 1 means to a monitoring system
+	checkCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &checkStatusError{status: monitor.Unknown, err: types.NewCodedError(types.ErrCodeInvalidArgument, err)}
+	})
+}
+
+// checkStatusError carries a non-OK check status so the process exits with its plugin exit code.
+// Without err the status line was already printed, with it the check could not run because of err.
+type checkStatusError struct {
+	status monitor.Status
+	err    error
+}
+
+func (e *checkStatusError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+
+	return fmt.Sprintf("check status %v", e.status)
+}
+
+func (e *checkStatusError) Unwrap() error {
+	return e.err
+}
+
+// validateThresholds rejects a critical threshold above the warning one, which would never warn.
+func validateThresholds() error {
+	if checkOptions.Critical > checkOptions.Warning {
+		return types.NewCodedError(types.ErrCodeInvalidArgument,
+			fmt.Errorf("--critical (%v) must not be longer than --warning (%v)", checkOptions.Critical, checkOptions.Warning))
+	}
+	if checkOptions.RefreshCritical > checkOptions.RefreshWarning {
+		return types.NewCodedError(types.ErrCodeInvalidArgument,
+			fmt.Errorf("--refresh-critical (%v) must not be longer than --refresh-warning (%v)", checkOptions.RefreshCritical, checkOptions.RefreshWarning))
+	}
+
+	return nil
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+
+	if err := validateThresholds(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report := monitor.Report{Checks: []monitor.Check{}, Perfdata: []monitor.Perfdata{}}
+	labels := map[string]string{}
+
+	tokens, err := utils.LoadLoginResponseFromFile(Options.OutputPath)
+	if err != nil {
+		report.Add(monitor.Check{Name: "auth file", Status: monitor.Unknown, Message: fmt.Sprintf("cannot read auth file: %v", err)})
+	} else {
+		labels["account"] = tokens.Account
+		labels["region"] = tokens.Region
+
+		check, perfdata := monitor.ExpiryCheck("token", "token_remaining", tokens.ExpiresAt, now,
+			monitor.Thresholds{Warning: checkOptions.Warning, Critical: checkOptions.Critical})
+		report.Add(check, perfdata...)
+
+		check, perfdata = monitor.ExpiryCheck("refresh token", "refresh_token_remaining", tokens.RefreshExpiresAt, now,
+			monitor.Thresholds{Warning: checkOptions.RefreshWarning, Critical: checkOptions.RefreshCritical})
+		report.Add(check, perfdata...)
+	}
+
+	var profileUp *bool
+	var profileDuration time.Duration
+	if tokens != nil && !checkOptions.SkipProfile {
+		up := true
+		profileUp = &up
+
+		check, perfdata := checkProfile(&profileDuration)
+		if check.Status != monitor.OK {
+			up = false
+		}
+		report.Add(check, perfdata...)
+	}
+
+	if checkOptions.Prometheus != consts.EMPTY_STRING {
+		metrics := checkMetrics(report, tokens, labels, profileUp, profileDuration, now)
+		if err := writeTextfile(checkOptions.Prometheus, metrics); err != nil {
+			report.Add(monitor.Check{Name: "textfile", Status: monitor.Unknown, Message: err.Error()})
+		}
+	}
+
+	status := report.Status()
+	line := report.Nagios(checkService)
+
+	if isJSONOutput() {
+		result := output.NewResult(cmd.Name())
+		result.Message = line
+		result.Account = labels["account"]
+		result.Region = labels["region"]
+		if checkOptions.Prometheus != consts.EMPTY_STRING {
+			result.Files = []string{checkOptions.Prometheus}
+		}
+		result.Data = map[string]any{"status": status, "checks": report.Checks, "perfdata": report.Perfdata}
+		if err := writeResult(cmd, result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(cmd.OutOrStdout(), line)
+	}
+
+	if status != monitor.OK {
+		return &checkStatusError{status: status}
+	}
+
+	return nil
+}
+
+// checkProfile calls the profile endpoint with the saved session, timing the request.
+func checkProfile(duration *time.Duration) (monitor.Check, []monitor.Perfdata) {
+	check := monitor.Check{Name: "profile"}
+
+	if _, err := auth.LoadEphemeralSession(&Options); err != nil {
+		check.Status = monitor.Critical
+		check.Message = fmt.Sprintf("session cannot be used: %v", err)
+		return check, nil
+	}
+
+	start := time.Now()
+	_, err := auth.FetchProfile(Options.UserRegion)
+	*duration = time.Since(start)
+
+	perfdata := monitor.Perfdata{
+		Label: "profile_time",
+		Value: duration.Round(time.Millisecond).Seconds(),
+		Unit:  "s",
+		Min:   "0",
+	}
+
+	if err != nil {
+		check.Status = monitor.Critical
+		check.Message = fmt.Sprintf("profile endpoint failed: %v", err)
+		return check, []monitor.Perfdata{perfdata}
+	}
+
+	check.Status = monitor.OK
+	check.Message = "profile endpoint OK"
+
+	return check, []monitor.Perfdata{perfdata}
+}
+
+// checkMetrics turns the report into node_exporter textfile metrics.
+func checkMetrics(report monitor.Report, tokens *types.LoginResponse, labels map[string]string, profileUp *bool, profileDuration time.Duration, now time.Time) []monitor.Metric {
+	withLabels := func(extra map[string]string) map[string]string {
+		merged := map[string]string{}
+		for name, value := range labels {
+			merged[name] = value
+		}
+		for name, value := range extra {
+			merged[name] = value
+		}
+		return merged
+	}
+
+	metrics := []monitor.Metric{}
+	if tokens != nil {
+		for _, token := range []struct {
+			name      string
+			expiresAt int64
+		}{{"access", tokens.ExpiresAt}, {"refresh", tokens.RefreshExpiresAt}} {
+			if token.expiresAt == 0 {
+				continue
+			}
+			tokenLabels := withLabels(map[string]string{"token": token.name})
+			metrics = append(metrics,
+				monitor.Metric{Name: "bambu_token_expiry_timestamp_seconds", Help: "Unix time the token expires at.", Labels: tokenLabels, Value: float64(token.expiresAt)},
+				monitor.Metric{Name: "bambu_token_remaining_seconds", Help: "Seconds left before the token expires, negative once expired.", Labels: tokenLabels, Value: float64(token.expiresAt - now.Unix())},
+			)
+		}
+	}
+
+	if profileUp != nil {
+		up := 0.0
+		if *profileUp {
+			up = 1
+		}
+		metrics = append(metrics,
+			monitor.Metric{Name: "bambu_profile_up", Help: "Whether the profile endpoint accepted the session.", Labels: withLabels(nil), Value: up},
+			monitor.Metric{Name: "bambu_profile_request_duration_seconds", Help: "Duration of the profile request.", Labels: withLabels(nil), Value: profileDuration.Seconds()},
+		)
+	}
+
+	metrics = append(metrics,
+		monitor.Metric{Name: "bambu_check_status", Help: "Plugin status of the check: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.", Labels: withLabels(nil), Value: float64(report.Status())},
+		monitor.Metric{Name: "bambu_check_timestamp_seconds", Help: "Unix time of the last check.", Labels: withLabels(nil), Value: float64(now.Unix())},
+	)
+
+	return metrics
+}
+
+// writeTextfile replaces the textfile atomically, the collector must never read a partial file.
+func writeTextfile(path string, metrics []monitor.Metric) error {
+	if !strings.HasSuffix(path, ".prom") {
+		slog.Warn("the node_exporter textfile collector only reads files ending in .prom", "path", path)
+	}
+
+	if err := utils.WriteFileAtomic(path, []byte(monitor.Prometheus(metrics)), 0644); err != nil {
+		return fmt.Errorf("failed to write prometheus textfile: %v", err)
+	}
+
+	return nil
+}
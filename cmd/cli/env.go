@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/shellenv"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	envCmd = &cobra.Command{
+		Use:   "env",
+		Short: "Print shell statements that export the saved token",
+		Long: `Print shell statements that export BAMBU_TOKEN, BAMBU_REFRESH_TOKEN,
+BAMBU_TOKEN_EXPIRES_AT and BAMBU_REGION from the saved auth file, e.g.
+
+  eval "$(bambulab-authenticator env)"                          # bash, zsh
+  bambulab-authenticator env --shell fish | source              # fish
+  bambulab-authenticator env --shell powershell | Invoke-Expression
+
+With --refresh an expired token is refreshed, and saved, first.`,
+		Args: cobra.ExactArgs(0),
+		RunE: runEnv,
+	}
+)
+
+func initEnvFlags() {
+
+	envCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	envCmd.Flags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region (defaults to the region saved with the token)")
+	envCmd.Flags().StringVar(&Options.Shell, "shell", consts.EMPTY_STRING, "Shell syntax: bash, zsh, fish or powershell (detected from $SHELL by default)")
+	envCmd.Flags().BoolVar(&Options.Refresh, "refresh", false, "Refresh the token first if it has expired")
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+
+	syntax := shellenv.Detect()
+	if Options.Shell != consts.EMPTY_STRING {
+		parsed, err := shellenv.Parse(Options.Shell)
+		if err != nil {
+			return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+		}
+		syntax = parsed
+	}
+
+	tokens, err := loadEnvTokens()
+	if err != nil {
+		return err
+	}
+
+	if tokens.ExpiresWithin(time.Now(), 0) {
+		slog.Warn("the saved token has expired, use --refresh or authenticate again")
+	}
+
+	env := auth.TokenEnvironment(tokens, Options.UserRegion)
+	script, err := shellenv.Format(syntax, env)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{}
+	for _, variable := range env {
+		data[variable.Name] = variable.Value
+	}
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Message = strings.TrimSuffix(script, "\n")
+	result.Account = tokens.Account
+	result.Region = Options.UserRegion
+	result.Data = data
+	if Options.Refresh {
+		result.Files = []string{utils.AuthFilePath(Options.OutputPath)}
+	}
+
+	return writeResult(cmd, result)
+}
+
+// loadEnvTokens reads the saved tokens, going through a session that refreshes them when --refresh is set.
+func loadEnvTokens() (*types.LoginResponse, error) {
+	if Options.Refresh {
+		if _, err := auth.LoadSession(&Options); err != nil {
+			return nil, err
+		}
+		return auth.CurrentTokens()
+	}
+
+	tokens, err := utils.LoadLoginResponseFromFile(Options.OutputPath)
+	if err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, err)
+	}
+
+	if Options.UserRegion == consts.EMPTY_STRING {
+		Options.UserRegion = tokens.Region
+	}
+
+	return tokens, nil
+}
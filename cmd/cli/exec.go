@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/childprocess"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	execCmd = &cobra.Command{
+		Use:   "exec [flags] -- <command> [args...]",
+		Short: "Run a command with the saved token in its environment",
+		Long: `Run a command with the saved session exposed as environment variables:
+BAMBU_TOKEN, BAMBU_REFRESH_TOKEN, BAMBU_TOKEN_EXPIRES_AT, BAMBU_UID, BAMBU_REGION,
+BAMBU_MQTT_HOST, BAMBU_MQTT_PORT, BAMBU_MQTT_USERNAME and BAMBU_MQTT_PASSWORD.
+
+An expired token is refreshed for the run but never written back to disk. The command's
+exit code is passed through and termination signals are forwarded to it. The command owns
+stdout, so no result document is printed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runExec,
+	}
+)
+
+func initExecFlags() {
+
+	execCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	execCmd.Flags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region (defaults to the region saved with the token)")
+
+	// Everything after the command belongs to the command
+	execCmd.Flags().SetInterspersed(false)
+}
+
+// childExitError carries a child's non-zero exit code so the process exits with it without printing an error.
+type childExitError struct {
+	code int
+}
+
+func (e *childExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.code)
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+
+	if _, err := auth.LoadEphemeralSession(&Options); err != nil {
+		return err
+	}
+
+	env, err := auth.SessionEnvironment(Options.UserRegion)
+	if err != nil {
+		return err
+	}
+
+	code, err := childprocess.Run(args[0], args[1:], env)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &childExitError{code: code}
+	}
+
+	return nil
+}
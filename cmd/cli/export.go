@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/export"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOptions = types.ExportFlags{}
+	exportCmd     = &cobra.Command{
+		Use:   "export",
+		Short: "Export the saved session for other tools",
+		Long: `Export the saved session for other tools. The token is refreshed, and saved,
+first when it has expired.`,
+	}
+	exportK8sSecretCmd = &cobra.Command{
+		Use:   "k8s-secret",
+		Short: "Print the session as a Kubernetes Secret manifest",
+		Long: `Print the session as a v1 Secret manifest with the BAMBU_* variables as keys, so a pod
+can load them with envFrom, e.g.
+
+  bambulab-authenticator export k8s-secret --namespace printers | kubectl apply -f -
+  bambulab-authenticator export k8s-secret --namespace printers | kubeseal -o yaml > sealed.yaml
+
+With --kustomize-dir a kustomization.yaml with a secretGenerator and its env file
+are written instead.`,
+		Args: cobra.ExactArgs(0),
+		RunE: runExportK8sSecret,
+	}
+	exportHomeAssistantCmd = &cobra.Command{
+		Use:   "home-assistant",
+		Short: "Print or merge the session and printer access codes as Home Assistant secrets",
+		Long: `Print the token, refresh token, uid, region and the serial and LAN access code of
+every printer as Home Assistant secrets.yaml entries. With --secrets-file the entries
+are merged into an existing secrets.yaml, keeping its other keys and comments, e.g.
+
+  bambulab-authenticator export home-assistant --secrets-file /config/secrets.yaml`,
+		Args: cobra.ExactArgs(0),
+		RunE: runExportHomeAssistant,
+	}
+)
+
+func initExportFlags() {
+
+	exportCmd.PersistentFlags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	exportCmd.PersistentFlags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region (defaults to the region saved with the token)")
+	exportCmd.PersistentFlags().StringVarP(&exportOptions.File, "file", "f", consts.EMPTY_STRING, "Write the export to this file instead of stdout")
+
+	exportK8sSecretCmd.Flags().StringVar(&exportOptions.Name, "name", export.DefaultSecretName, "Secret name")
+	exportK8sSecretCmd.Flags().StringVar(&exportOptions.Namespace, "namespace", consts.EMPTY_STRING, "Secret namespace")
+	exportK8sSecretCmd.Flags().StringToStringVar(&exportOptions.Labels, "label", nil, "Secret label as key=value (repeatable)")
+	exportK8sSecretCmd.Flags().StringVar(&exportOptions.SealedSecretScope, "sealed-secret-scope", consts.EMPTY_STRING, "Annotate the Secret for kubeseal: strict, namespace-wide or cluster-wide")
+	exportK8sSecretCmd.Flags().StringVar(&exportOptions.KustomizeDir, "kustomize-dir", consts.EMPTY_STRING, "Write a kustomize secretGenerator to this directory instead of a Secret manifest")
+
+	exportHomeAssistantCmd.Flags().StringVar(&exportOptions.SecretsFile, "secrets-file", consts.EMPTY_STRING, "Merge the entries into this secrets.yaml instead of printing them")
+	exportHomeAssistantCmd.Flags().StringVar(&exportOptions.Prefix, "prefix", export.DefaultSecretsPrefix, "Prefix of every secret key")
+
+	exportCmd.AddCommand(exportK8sSecretCmd)
+	exportCmd.AddCommand(exportHomeAssistantCmd)
+}
+
+func runExportK8sSecret(cmd *cobra.Command, args []string) error {
+
+	opts := export.K8sSecretOptions{
+		Name:              exportOptions.Name,
+		Namespace:         exportOptions.Namespace,
+		Labels:            exportOptions.Labels,
+		SealedSecretScope: exportOptions.SealedSecretScope,
+	}
+	if err := opts.Validate(); err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+
+	tokens, env, err := loadExportSession()
+	if err != nil {
+		return err
+	}
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Account = tokens.Account
+	result.Region = Options.UserRegion
+	data := map[string]any{"keys": envNames(env)}
+	result.Data = data
+
+	if exportOptions.KustomizeDir != consts.EMPTY_STRING {
+		files, err := export.Kustomize(env, opts)
+		if err != nil {
+			return err
+		}
+		written, err := writeExportFiles(exportOptions.KustomizeDir, files)
+		if err != nil {
+			return err
+		}
+		result.Message = fmt.Sprintf("Kustomization written to %s", exportOptions.KustomizeDir)
+		result.Files = append(result.Files, written...)
+		return writeResult(cmd, result)
+	}
+
+	manifest, err := export.K8sSecret(env, opts)
+	if err != nil {
+		return err
+	}
+
+	secrets := make([]exportedSecret, 0, len(env))
+	for _, variable := range env {
+		secrets = append(secrets, exportedSecret{Key: variable.Name, Value: variable.Value})
+	}
+
+	return writeExport(cmd, result, data, manifest, secrets)
+}
+
+func runExportHomeAssistant(cmd *cobra.Command, args []string) error {
+
+	if _, err := auth.LoadSession(&Options); err != nil {
+		return err
+	}
+
+	uid := consts.EMPTY_STRING
+	if profile, err := auth.FetchProfile(Options.UserRegion); err != nil {
+		slog.Warn("could not determine uid, omitting it", "error", err)
+	} else {
+		uid = profile.UID.String()
+	}
+
+	devices, err := auth.FetchDevices(Options.UserRegion)
+	if err != nil {
+		slog.Warn("could not list printers, omitting serials and access codes", "error", err)
+	}
+
+	tokens, err := auth.CurrentTokens()
+	if err != nil {
+		return err
+	}
+
+	entries := export.HomeAssistantSecrets(exportOptions.Prefix, tokens, uid, Options.UserRegion, devices)
+
+	keys := make([]string, 0, len(entries))
+	secrets := make([]exportedSecret, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+		secrets = append(secrets, exportedSecret{Key: entry.Key, Value: entry.Value})
+	}
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Account = tokens.Account
+	result.Region = Options.UserRegion
+	data := map[string]any{"keys": keys, "printers": len(devices)}
+	result.Data = data
+
+	if exportOptions.SecretsFile == consts.EMPTY_STRING {
+		snippet, err := export.FormatSecrets(entries)
+		if err != nil {
+			return err
+		}
+		return writeExport(cmd, result, data, snippet, secrets)
+	}
+
+	if err := mergeSecretsFile(exportOptions.SecretsFile, entries); err != nil {
+		return err
+	}
+
+	result.Message = fmt.Sprintf("Secrets merged into %s", exportOptions.SecretsFile)
+	result.Files = []string{exportOptions.SecretsFile}
+
+	return writeResult(cmd, result)
+}
+
+// mergeSecretsFile merges the entries into the secrets file, creating it when missing. The file is
+// replaced atomically and keeps its permissions.
+func mergeSecretsFile(path string, entries []export.Entry) error {
+	mode := os.FileMode(0600)
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if info, statErr := os.Stat(path); statErr == nil {
+			mode = info.Mode().Perm()
+		}
+	case errors.Is(err, os.ErrNotExist):
+		existing = nil
+	default:
+		return fmt.Errorf("failed to read secrets file: %v", err)
+	}
+
+	merged, err := export.MergeSecrets(existing, entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".secrets-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(merged); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+
+	return nil
+}
+
+// loadExportSession loads the saved session, refreshing and saving the tokens when needed, and
+// describes it as environment variables.
+func loadExportSession() (*types.LoginResponse, []types.EnvVar, error) {
+	if _, err := auth.LoadSession(&Options); err != nil {
+		return nil, nil, err
+	}
+
+	env, err := auth.SessionEnvironment(Options.UserRegion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := auth.CurrentTokens()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, env, nil
+}
+
+// writeExport prints the exported document, or writes it to --file.
+// exportedSecret is one exported value in the json result.
+type exportedSecret struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// writeExport prints the document, or writes it to --file. In json mode a printed export is returned
+// as data.secrets rather than as the document text, so consumers need not parse it.
+func writeExport(cmd *cobra.Command, result output.Result, data map[string]any, document []byte, secrets []exportedSecret) error {
+	result.Data = data
+
+	if exportOptions.File == consts.EMPTY_STRING {
+		if isJSONOutput() {
+			data["secrets"] = secrets
+			result.Message = fmt.Sprintf("%d secrets exported", len(secrets))
+			return writeResult(cmd, result)
+		}
+		result.Message = strings.TrimSuffix(string(document), "\n")
+		return writeResult(cmd, result)
+	}
+
+	if err := os.WriteFile(exportOptions.File, document, 0600); err != nil {
+		return fmt.Errorf("failed to write export: %v", err)
+	}
+
+	result.Message = fmt.Sprintf("Export written to %s", exportOptions.File)
+	result.Files = append(result.Files, exportOptions.File)
+
+	return writeResult(cmd, result)
+}
+
+// writeExportFiles writes the named files into dir, creating it if needed, and returns their paths.
+func writeExportFiles(dir string, files map[string][]byte) ([]string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, files[name], 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %v: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func envNames(env []types.EnvVar) []string {
+	names := make([]string, 0, len(env))
+	for _, variable := range env {
+		names = append(names, variable.Name)
+	}
+
+	return names
+}
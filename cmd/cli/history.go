@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/history"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "List or restore previous auth files",
+		Long: `Every login keeps the auth file it replaces in the history directory next to it, up to
+--history-size files. Use list to see them and restore to roll back to one.`,
+	}
+	historyListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the previous auth files, newest first",
+		Args:  cobra.ExactArgs(0),
+		RunE:  runHistoryList,
+	}
+	historyRestoreCmd = &cobra.Command{
+		Use:   "restore <n>",
+		Short: "Make entry n of the list the current auth file",
+		Long: `Make entry n of the list the current auth file. The entry leaves the history and the
+auth file it replaces takes its place, so list the history again before another restore.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runHistoryRestore,
+	}
+)
+
+func initHistoryFlags() {
+
+	historyCmd.PersistentFlags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	historyRestoreCmd.Flags().IntVar(&Options.HistorySize, "history-size", history.DefaultSize, "How many previous auth files to keep in the history directory")
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyRestoreCmd)
+}
+
+// historyEntry is an entry as reported in the json output.
+type historyEntry struct {
+	history.Entry
+	Account          string `json:"account,omitempty"`
+	Region           string `json:"region,omitempty"`
+	Status           string `json:"status"`
+	ExpiresAt        string `json:"expiresAt,omitempty"`
+	RefreshExpiresAt string `json:"refreshExpiresAt,omitempty"`
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) error {
+
+	entries, err := history.List(Options.OutputPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reported := make([]historyEntry, 0, len(entries))
+	for _, entry := range entries {
+		reported = append(reported, newHistoryEntry(entry, now))
+	}
+
+	result := output.NewResult(cmd.Name())
+	result.Message = historyTable(reported)
+	result.Data = map[string]any{"entries": reported}
+
+	return writeResult(cmd, result)
+}
+
+func runHistoryRestore(cmd *cobra.Command, args []string) error {
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("invalid history entry %q, expected a number from history list", args[0]))
+	}
+
+	entry, err := history.Restore(Options.OutputPath, n, Options.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	status := entry.Status(time.Now())
+	if status == history.StatusExpired {
+		slog.Warn("the restored tokens have expired, log in again if they are rejected")
+	}
+
+	authFilePath := utils.AuthFilePath(Options.OutputPath)
+	result := output.NewResult(cmd.Name()).WithTokens(entry.Tokens)
+	result.Message = fmt.Sprintf("Restored the auth file saved %s to %s", entry.SavedAt.Local().Format(time.DateTime), authFilePath)
+	result.Account = entry.Tokens.Account
+	result.Region = entry.Tokens.Region
+	result.Files = []string{authFilePath}
+	result.Data = map[string]any{"restored": newHistoryEntry(*entry, time.Now())}
+
+	return writeResult(cmd, result)
+}
+
+func newHistoryEntry(entry history.Entry, now time.Time) historyEntry {
+	reported := historyEntry{Entry: entry, Status: entry.Status(now)}
+	if entry.Tokens == nil {
+		return reported
+	}
+
+	reported.Account = entry.Tokens.Account
+	reported.Region = entry.Tokens.Region
+	if entry.Tokens.ExpiresAt != 0 {
+		reported.ExpiresAt = time.Unix(entry.Tokens.ExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	if entry.Tokens.RefreshExpiresAt != 0 {
+		reported.RefreshExpiresAt = time.Unix(entry.Tokens.RefreshExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	return reported
+}
+
+// historyTable renders the entries as an aligned table for text output.
+func historyTable(entries []historyEntry) string {
+	if len(entries) == 0 {
+		return "No previous auth files"
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSAVED\tACCOUNT\tREGION\tSTATUS\tEXPIRES")
+	for _, entry := range entries {
+		expires := "-"
+		if entry.ExpiresAt != "" {
+			expires = entry.ExpiresAt
+		}
+		status := entry.Status
+		if entry.Error != "" {
+			status = "unreadable"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", entry.Index, entry.SavedAt.Local().Format(time.DateTime),
+			orDash(entry.Account), orDash(entry.Region), status, expires)
+	}
+	w.Flush()
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
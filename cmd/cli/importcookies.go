@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/cookies"
+	"github.com/ondrovic/bambulab-authenticator/internal/history"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importCookiesCmd = &cobra.Command{
+		Use:   "import-cookies <cookies-file>",
+		Short: "Save a session from the cookies of a logged-in browser",
+		Long: `Save a session from the cookies of a logged-in bambulab.com browser session, for
+accounts that sign in with Google or Apple and cannot use the password login.
+
+The file may be a Netscape cookies.txt or a JSON export (Cookie-Editor, EditThisCookie
+or Playwright storage state). Pass - to read it from stdin. The tokens are checked
+against the profile endpoint before the auth file is saved.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runImportCookies,
+	}
+)
+
+func initImportCookiesFlags() {
+
+	importCookiesCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Output path of the authentication info")
+	importCookiesCmd.Flags().StringVarP(&Options.UserRegion, "user-region", "r", consts.EMPTY_STRING, "User region (inferred from the cookie domain by default)")
+	importCookiesCmd.Flags().IntVar(&Options.HistorySize, "history-size", history.DefaultSize, "How many previous auth files to keep in the history directory, 0 to keep none")
+}
+
+func runImportCookies(cmd *cobra.Command, args []string) error {
+
+	data, err := readCookiesFile(args[0])
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+
+	browserCookies, err := cookies.Parse(data)
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+
+	tokens, err := auth.ImportCookies(browserCookies, &Options)
+	if err != nil {
+		return err
+	}
+
+	authFilePath := utils.AuthFilePath(Options.OutputPath)
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Message = fmt.Sprintf("Auth data saved to %s", authFilePath)
+	result.Account = tokens.Account
+	result.Region = tokens.Region
+	result.Files = []string{authFilePath}
+
+	return writeResult(cmd, result)
+}
+
+func readCookiesFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies file: %v", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/jwt"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectOptions = types.InspectFlags{}
+	inspectCmd     = &cobra.Command{
+		Use:   "inspect",
+		Short: "Decode the claims of the access token",
+		Long: `Decode the claims of the saved access token, such as uid, issue and expiry time and
+region, and report when the claimed expiry disagrees with the one saved in the auth file.
+
+With --stdin the token is read from stdin instead, either raw or as an auth file.
+The token itself is only printed with --show-token. Its signature is not verified.`,
+		Args: cobra.ExactArgs(0),
+		RunE: runInspect,
+	}
+)
+
+func initInspectFlags() {
+
+	inspectCmd.Flags().StringVarP(&Options.OutputPath, "output-path", "o", ".", "Path of the saved authentication info")
+	inspectCmd.Flags().BoolVar(&inspectOptions.Stdin, "stdin", false, "Read the token, or an auth file, from stdin")
+	inspectCmd.Flags().BoolVar(&inspectOptions.ShowToken, "show-token", false, "Also print the raw access token")
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+
+	tokens, err := loadInspectTokens()
+	if err != nil {
+		return err
+	}
+
+	token, err := jwt.Decode(tokens.AccessToken)
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeSessionInvalid, err)
+	}
+
+	mismatches := token.ExpiryMismatches(tokens)
+	if mismatches == nil {
+		mismatches = []string{}
+	}
+	for _, mismatch := range mismatches {
+		slog.Warn("expiry mismatch", "detail", mismatch)
+	}
+
+	if exp, ok := token.Time("exp"); ok && !time.Now().Before(exp) {
+		slog.Warn("the access token has expired", "expiredAt", exp.UTC().Format(time.RFC3339))
+	}
+
+	data := map[string]any{
+		"header":     token.Header,
+		"claims":     token.Claims,
+		"mismatches": mismatches,
+	}
+	if inspectOptions.ShowToken {
+		data["token"] = tokens.AccessToken
+	}
+
+	result := output.NewResult(cmd.Name()).WithTokens(tokens)
+	result.Message = claimsTable(token, tokens.AccessToken)
+	result.Account = tokens.Account
+	result.Region = tokens.Region
+	result.Data = data
+
+	return writeResult(cmd, result)
+}
+
+// loadInspectTokens reads the auth file, or stdin holding either an auth file or a raw token.
+func loadInspectTokens() (*types.LoginResponse, error) {
+	if !inspectOptions.Stdin {
+		tokens, err := utils.LoadLoginResponseFromFile(Options.OutputPath)
+		if err != nil {
+			return nil, types.NewCodedError(types.ErrCodeSessionInvalid, err)
+		}
+		return tokens, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %v", err)
+	}
+	data = bytes.TrimSpace(data)
+
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return &types.LoginResponse{AccessToken: string(data)}, nil
+	}
+
+	var tokens types.LoginResponse
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("failed to parse auth file from stdin: %v", err))
+	}
+
+	return &tokens, nil
+}
+
+// claimsTable renders the claims sorted by name, with timestamps shown as dates.
+func claimsTable(token *jwt.Token, raw string) string {
+	names := make([]string, 0, len(token.Claims))
+	for name := range token.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLAIM\tVALUE")
+	for _, name := range names {
+		value := fmt.Sprint(token.Claims[name])
+		if at, ok := token.Time(name); ok && jwt.IsTimeClaim(name) {
+			value = fmt.Sprintf("%s (%s)", at.UTC().Format(time.RFC3339), value)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, value)
+	}
+	if alg, ok := token.Header["alg"]; ok {
+		fmt.Fprintf(w, "%s\t%v\n", "(alg)", alg)
+	}
+	if inspectOptions.ShowToken {
+		fmt.Fprintf(w, "%s\t%s\n", "(token)", raw)
+	}
+	w.Flush()
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
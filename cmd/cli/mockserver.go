@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/mockserver"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockServerOptions = types.MockServerFlags{}
+	mockServerCmd     = &cobra.Command{
+		Use:   "mock-server",
+		Short: "Run a local mock of the Bambu cloud login endpoints for offline testing",
+		Long: `Run a local mock of the Bambu cloud login, email and SMS code, TFA, refresh, profile and device list endpoints.
+
+Point other commands at it with --base-url, for example:
+  bambulab-authenticator mock-server --scenario totp
+  bambulab-authenticator authenticate --base-url http://127.0.0.1:8080 -u user@example.com -p password -r us -o .
+
+Scenarios: password-only, email-code, totp, wrong-code, rate-limit, china.
+China region endpoints are served under the /cn path prefix.`,
+		Args: cobra.ExactArgs(0),
+		RunE: runMockServer,
+	}
+)
+
+func initMockServerFlags() {
+
+	mockServerCmd.Flags().StringVarP(&mockServerOptions.Listen, "listen", "l", "127.0.0.1:8080", "Address to listen on")
+	mockServerCmd.Flags().StringVarP(&mockServerOptions.Scenario, "scenario", "s", string(mockserver.PasswordOnly), "Login scenario to emulate")
+	mockServerCmd.Flags().StringVarP(&mockServerOptions.Account, "account", "a", mockserver.DefaultAccount, "Account accepted by the mock")
+	mockServerCmd.Flags().StringVarP(&mockServerOptions.Password, "password", "p", mockserver.DefaultPassword, "Password accepted by the mock")
+	mockServerCmd.Flags().StringVarP(&mockServerOptions.Code, "code", "c", mockserver.DefaultCode, "Email code or one-time password accepted by the mock")
+	mockServerCmd.Flags().DurationVar(&mockServerOptions.CodeCooldown, "code-cooldown", 0, "Minimum time between two email or SMS codes, earlier requests get 429")
+	mockServerCmd.Flags().BoolVar(&mockServerOptions.OmitTokens, "omit-tokens", false, "Answer successful logins without tokens, to test how a broken response is handled")
+	mockServerCmd.Flags().IntVar(&mockServerOptions.RejectCodes, "reject-codes", 0, "Number of codes the wrong-code scenario rejects before accepting one")
+}
+
+func runMockServer(cmd *cobra.Command, args []string) error {
+
+	scenario, err := mockserver.ParseScenario(mockServerOptions.Scenario)
+	if err != nil {
+		return err
+	}
+
+	_, server, err := mockserver.Listen(mockserver.Config{
+		Scenario:     scenario,
+		Account:      mockServerOptions.Account,
+		Password:     mockServerOptions.Password,
+		Code:         mockServerOptions.Code,
+		RejectCodes:  mockServerOptions.RejectCodes,
+		CodeCooldown: mockServerOptions.CodeCooldown,
+		OmitTokens:   mockServerOptions.OmitTokens,
+	}, mockServerOptions.Listen)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	slog.Info("mock server listening", "scenario", scenario, "url", server.URL, "chinaPathPrefix", consts.ChinaPathPrefix)
+
+	result := output.NewResult(cmd.Name())
+	result.Message = server.URL
+	result.Data = map[string]string{
+		"url":      server.URL,
+		"scenario": string(scenario),
+	}
+	if err := writeResult(cmd, result); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	return nil
+}
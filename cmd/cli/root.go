@@ -1,27 +1,253 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/logger"
+	"github.com/ondrovic/bambulab-authenticator/internal/monitor"
+	"github.com/ondrovic/bambulab-authenticator/internal/output"
 	"github.com/ondrovic/bambulab-authenticator/internal/types"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// ExitCodeError is the exit code for any failure without a more specific code.
+	ExitCodeError = 1
+	// ExitCodeCodeRequired is the exit code when a verification code is needed in non-interactive mode.
+	ExitCodeCodeRequired = 3
+	// ExitCodeRateLimited is the exit code when a login attempt is refused to respect a cooldown.
+	ExitCodeRateLimited = 4
 )
 
+// insecureWarning is printed on every run with --insecure.
+const insecureWarning = `
+WARNING: TLS certificate verification is disabled (--insecure).
+WARNING: Anyone on the network path can read your password, codes and tokens.
+WARNING: Prefer --ca-file with your proxy's CA certificate.
+`
+
+// exitCodes maps error codes to process exit codes.
+var exitCodes = map[string]int{
+	types.ErrCodeCodeRequired: ExitCodeCodeRequired,
+	types.ErrCodeRateLimited:  ExitCodeRateLimited,
+}
+
 var (
 	Options = types.CliFlags{}
 	RootCmd = &cobra.Command{
-		Use:   "bambulab-authenticator",
-		Short: "A CLI tool to export authentication info to a json file",
+		Use:               "bambulab-authenticator",
+		Short:             "A CLI tool to export authentication info to a json file",
+		PersistentPreRunE: runPersistentPreRun,
+		// Errors are printed by Execute so they can be rendered in the selected output format
+		SilenceErrors: true,
 	}
 )
 
 func InitializeCommands() {
+	initRootFlags()
 	initAuthenticateFlags()
+	initMockServerFlags()
+	initExecFlags()
+	initEnvFlags()
+	initExportFlags()
+	initImportCookiesFlags()
+	initInspectFlags()
+	initHistoryFlags()
+	initCheckFlags()
 	RootCmd.AddCommand(authenticateCmd)
+	RootCmd.AddCommand(mockServerCmd)
+	RootCmd.AddCommand(execCmd)
+	RootCmd.AddCommand(envCmd)
+	RootCmd.AddCommand(exportCmd)
+	RootCmd.AddCommand(importCookiesCmd)
+	RootCmd.AddCommand(inspectCmd)
+	RootCmd.AddCommand(historyCmd)
+	RootCmd.AddCommand(checkCmd)
 }
 
-func Execute() error {
-	if err := RootCmd.Execute(); err != nil {
+func initRootFlags() {
+	RootCmd.PersistentFlags().StringVar(&Options.Output, "output", output.FormatText, "Result format: text or json (see README for the json schema)")
+	RootCmd.PersistentFlags().BoolVar(&Options.NonInteractive, "non-interactive", false, "Never prompt or clear the screen, fail instead (enabled automatically when stdin is not a terminal)")
+	RootCmd.PersistentFlags().StringVar(&Options.BaseURL, "base-url", consts.EMPTY_STRING, "Send every request to this base URL instead of the Bambu cloud (e.g. a mock-server)")
+	RootCmd.PersistentFlags().StringVar(&Options.LogLevel, "log-level", "info", "Diagnostics log level: debug, info, warn or error")
+	RootCmd.PersistentFlags().StringVar(&Options.LogFormat, "log-format", logger.FormatText, "Diagnostics log format: text or json")
+	RootCmd.PersistentFlags().BoolVarP(&Options.Verbose, "verbose", "v", false, "Log each HTTP request and response status with timing to stderr")
+	RootCmd.PersistentFlags().BoolVar(&Options.Trace, "trace", false, "Like --verbose, but also log headers and bodies (secrets are redacted)")
+	RootCmd.PersistentFlags().StringVar(&Options.Proxy, "proxy", consts.EMPTY_STRING, "Proxy URL (http, https, socks5 or socks5h), defaults to the HTTP(S)_PROXY environment variables")
+	RootCmd.PersistentFlags().StringArrayVar(&Options.CAFiles, "ca-file", nil, "PEM bundle of CA certificates to trust in addition to the system ones (repeatable)")
+	RootCmd.PersistentFlags().StringVar(&Options.ClientCert, "client-cert", consts.EMPTY_STRING, "PEM client certificate for TLS client authentication")
+	RootCmd.PersistentFlags().StringVar(&Options.ClientKey, "client-key", consts.EMPTY_STRING, "PEM key of --client-cert, if not in the certificate file")
+	RootCmd.PersistentFlags().StringVar(&Options.MinTLSVersion, "min-tls-version", consts.EMPTY_STRING, "Lowest accepted TLS version: 1.0, 1.1, 1.2 or 1.3")
+	RootCmd.PersistentFlags().BoolVar(&Options.Insecure, "insecure", false, "Do not verify TLS certificates (dangerous, tokens can be intercepted)")
+	RootCmd.PersistentFlags().StringVar(&Options.HeaderProfile, "header-profile", httpclient.ProfileBrowser, "Client whose headers are sent: "+strings.Join(httpclient.HeaderProfileNames(), ", "))
+	RootCmd.PersistentFlags().StringVar(&Options.HeaderProfileFile, "header-profile-file", consts.EMPTY_STRING, "YAML file with custom common, web and api headers, replaces --header-profile")
+	RootCmd.PersistentFlags().StringVar(&Options.HARPath, "har", consts.EMPTY_STRING, "Write a redacted HAR file of every HTTP exchange to this path")
+}
+
+func runPersistentPreRun(cmd *cobra.Command, args []string) error {
+	if err := output.ValidateFormat(Options.Output); err != nil {
+		return err
+	}
+
+	// Without a terminal nobody can answer a prompt
+	if !Options.NonInteractive && !term.IsTerminal(int(os.Stdin.Fd())) {
+		Options.NonInteractive = true
+	}
+
+	// A usage dump would corrupt the json document and clutter CI logs
+	if isJSONOutput() || Options.NonInteractive {
+		cmd.SilenceUsage = true
+	}
+
+	// HTTP traces are debug records, so asking for them implies debug logging
+	logLevel := Options.LogLevel
+	if Options.Verbose || Options.Trace {
+		logLevel = slog.LevelDebug.String()
+	}
+
+	// Results go to stdout, everything else is logged to stderr
+	if err := logger.Init(os.Stderr, logLevel, Options.LogFormat); err != nil {
 		return err
 	}
 
+	consts.SetBaseURL(Options.BaseURL)
+
+	transportOptions := httpclient.TransportOptions{
+		Proxy:         Options.Proxy,
+		CAFiles:       Options.CAFiles,
+		ClientCert:    Options.ClientCert,
+		ClientKey:     Options.ClientKey,
+		MinTLSVersion: Options.MinTLSVersion,
+		Insecure:      Options.Insecure,
+	}
+	if err := httpclient.ConfigureTransport(transportOptions); err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+	if Options.Insecure {
+		// Printed outside the logger so it shows whatever the log level
+		fmt.Fprint(os.Stderr, insecureWarning)
+	}
+
+	traceOptions := httpclient.TraceOptions{
+		Bodies:  Options.Trace,
+		HARPath: Options.HARPath,
+	}
+	if Options.Verbose || Options.Trace {
+		traceOptions.Logger = slog.Default()
+	}
+	httpclient.EnableTracing(traceOptions)
+
+	profile, err := selectHeaderProfile()
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+	httpclient.SetHeaderProfile(profile)
+
 	return nil
 }
+
+// selectHeaderProfile returns the custom header profile when a file is given, the named built-in one otherwise.
+func selectHeaderProfile() (httpclient.HeaderProfile, error) {
+	if Options.HeaderProfileFile != consts.EMPTY_STRING {
+		return httpclient.LoadHeaderProfile(Options.HeaderProfileFile)
+	}
+
+	return httpclient.ParseHeaderProfile(Options.HeaderProfile)
+}
+
+func isJSONOutput() bool {
+	return strings.EqualFold(Options.Output, output.FormatJSON)
+}
+
+// writeResult prints a command's result to stdout in the selected output format.
+func writeResult(cmd *cobra.Command, result output.Result) error {
+	return output.Write(cmd.OutOrStdout(), Options.Output, result)
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	var childExit *childExitError
+	if errors.As(err, &childExit) {
+		return childExit.code
+	}
+
+	var checkStatus *checkStatusError
+	if errors.As(err, &checkStatus) {
+		return int(checkStatus.status)
+	}
+
+	if code, ok := exitCodes[types.ErrorCode(err)]; ok {
+		return code
+	}
+
+	return ExitCodeError
+}
+
+// reportedError marks a failure whose result document, with the command's details, was already written.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// Execute runs the root command and reports a failure either as text on stderr or,
+// in json mode, as an error document on stdout.
+func Execute() error {
+	cmd, err := RootCmd.ExecuteC()
+	if err == nil {
+		return nil
+	}
+
+	// The child already reported its own failure
+	var childExit *childExitError
+	if errors.As(err, &childExit) {
+		return err
+	}
+
+	// The check already printed its status line
+	var checkStatus *checkStatusError
+	if errors.As(err, &checkStatus) && checkStatus.err == nil {
+		return err
+	}
+
+	// A check that could not run is UNKNOWN, and monitoring systems only read the status line on stdout
+	if cmd == checkCmd {
+		if checkStatus == nil {
+			err = &checkStatusError{status: monitor.Unknown, err: err}
+		}
+		if !isJSONOutput() {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %v - %v\n", checkService, monitor.Unknown, err)
+			return err
+		}
+	}
+
+	// The result document describing the failure was already written
+	var reported *reportedError
+	if errors.As(err, &reported) && isJSONOutput() {
+		return err
+	}
+
+	if isJSONOutput() {
+		if writeErr := output.Write(cmd.OutOrStdout(), output.FormatJSON, output.NewErrorResult(cmd.Name(), err)); writeErr != nil {
+			slog.Error("failed to write result", "error", writeErr)
+		}
+		return err
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "Error:", err)
+
+	return err
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// chinaCountryCode is the calling code of mainland China numbers.
+const chinaCountryCode = "86"
+
+// isPhoneAccount reports whether the account is a phone number rather than an email address.
+func isPhoneAccount(account string) bool {
+	if strings.Contains(account, "@") {
+		return false
+	}
+
+	digits := 0
+	for _, r := range account {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune("+ -.()", r):
+		default:
+			return false
+		}
+	}
+
+	return digits > 0
+}
+
+// normalizeAccount trims email accounts and rewrites phone accounts in the form the region expects:
+// China region numbers as the 11 digit mobile number without a country code, other regions in
+// E.164 form (+ followed by the country code and number).
+func normalizeAccount(account string, region string) (string, error) {
+	account = strings.TrimSpace(account)
+	if !isPhoneAccount(account) {
+		return account, nil
+	}
+
+	international := strings.HasPrefix(account, "+") || strings.HasPrefix(account, "00")
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, account)
+	if strings.HasPrefix(account, "00") {
+		digits = strings.TrimPrefix(digits, "00")
+	}
+
+	if strings.EqualFold(region, chinaRegion) {
+		if international || (len(digits) == 13 && strings.HasPrefix(digits, chinaCountryCode)) {
+			if !strings.HasPrefix(digits, chinaCountryCode) {
+				return "", fmt.Errorf("phone number %v is not a mainland China number", account)
+			}
+			digits = strings.TrimPrefix(digits, chinaCountryCode)
+		}
+		if len(digits) != 11 || digits[0] != '1' {
+			return "", fmt.Errorf("phone number %v is not an 11 digit mainland China mobile number", account)
+		}
+		return digits, nil
+	}
+
+	if !international {
+		return "", errors.New("phone numbers outside the China region must include the country code, e.g. +1 555 123 4567")
+	}
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", fmt.Errorf("phone number %v is not a valid international number", account)
+	}
+
+	return "+" + digits, nil
+}
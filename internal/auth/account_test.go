@@ -0,0 +1,43 @@
+package auth
+
+import "testing"
+
+func TestNormalizeAccount(t *testing.T) {
+	tests := []struct {
+		name      string
+		account   string
+		region    string
+		expected  string
+		expectErr bool
+	}{
+		{name: "Email", account: " user@example.com ", region: "us", expected: "user@example.com"},
+		{name: "Email with digits", account: "123@example.com", region: "china", expected: "123@example.com"},
+		{name: "China mobile", account: "138 0013 8000", region: "china", expected: "13800138000"},
+		{name: "China mobile with country code", account: "+86 138-0013-8000", region: "china", expected: "13800138000"},
+		{name: "China mobile with 0086", account: "008613800138000", region: "china", expected: "13800138000"},
+		{name: "China mobile with bare country code", account: "8613800138000", region: "china", expected: "13800138000"},
+		{name: "China region foreign number", account: "+1 555 123 4567", region: "china", expectErr: true},
+		{name: "China region short number", account: "12345", region: "china", expectErr: true},
+		{name: "International", account: "+1 (555) 123-4567", region: "us", expected: "+15551234567"},
+		{name: "International with 00", account: "0044 20 7946 0958", region: "eu", expected: "+442079460958"},
+		{name: "Missing country code", account: "555 123 4567", region: "us", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeAccount(tt.account, tt.region)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("normalizeAccount() = %v, expected an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAccount() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("normalizeAccount() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -2,14 +2,31 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/history"
 	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
 	"github.com/ondrovic/bambulab-authenticator/internal/types"
 	"github.com/ondrovic/bambulab-authenticator/internal/utils"
 )
 
+// Login methods selected with --method.
+const (
+	// MethodPassword logs in with the account password, followed by an email code or 2FA when asked.
+	MethodPassword = "password"
+	// MethodEmailCode logs in with a code sent by email alone, without the password.
+	MethodEmailCode = "email-code"
+)
+
+// Methods lists every supported login method.
+var Methods = []string{MethodPassword, MethodEmailCode}
+
 func Login(opts *types.CliFlags) error {
 
 	if httpclient.Client == nil {
@@ -18,9 +35,35 @@ func Login(opts *types.CliFlags) error {
 		}
 	}
 
+	account, err := normalizeAccount(opts.UserAccount, opts.UserRegion)
+	if err != nil {
+		return types.NewCodedError(types.ErrCodeInvalidArgument, err)
+	}
+	opts.UserAccount = account
+
+	if opts.SaveCookies {
+		loadCookies(opts.OutputPath)
+	}
+
+	switch opts.Method {
+	case consts.EMPTY_STRING, MethodPassword:
+		if err := checkLimits(opts, passwordAttempt, time.Now()); err != nil {
+			return err
+		}
+	case MethodEmailCode:
+		return verifyCodeFlow(opts)
+	default:
+		return types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("unknown login method %q, expected %v", opts.Method, strings.Join(Methods, ", ")))
+	}
+
+	password, err := resolvePassword(opts)
+	if err != nil {
+		return err
+	}
+
 	loginPayload := types.LoginPayload{
 		Account:  opts.UserAccount,
-		Password: opts.UserPassword,
+		Password: password,
 		ApiError: consts.EMPTY_STRING,
 	}
 
@@ -34,12 +77,25 @@ func Login(opts *types.CliFlags) error {
 		return fmt.Errorf("failed to construct regionalUrl: %v", err)
 	}
 
+	slog.Debug("logging in", "account", opts.UserAccount, "region", opts.UserRegion)
+
 	resp, err := httpclient.Request("POST", string(url), jsonLoginPayload)
 	if err != nil {
+		recordFailure(opts, err)
+		var statusErr *httpclient.StatusError
+		if errors.As(err, &statusErr) && statusErr.Rejected() {
+			notifyCredentialHelper(opts, password, false)
+		}
+		return err
+	}
+
+	// A rejected password comes back as an error status. A success without tokens or a verification
+	// step is a broken response, left to validation without erasing the password or counting a failure
+	if err := processLoginType(resp, opts); err != nil {
 		return err
 	}
 
-	processLoginType(resp, opts)
+	notifyCredentialHelper(opts, password, true)
 
 	return nil
 }
@@ -47,28 +103,47 @@ func Login(opts *types.CliFlags) error {
 func processLoginType(loginResponse *types.LoginResponse, opts *types.CliFlags) error {
 	switch loginResponse.LoginType {
 	case "verifyCode":
-		if err := sendCodeToEmail(opts); err != nil {
-			fmt.Printf("error sending email %v\n", err)
-			return err
-		}
-
-		fmt.Print("VerifyCode: Enter the code from your email: ")
-		var verifyCode string
-		fmt.Scanln(&verifyCode)
-
-		if err := emailCodeLogin(verifyCode, opts); err != nil {
-			return err
-		}
-
-		return nil
+		return verifyCodeFlow(opts)
 	case "tfa":
-		twoFactorAuth(loginResponse.TfaKey, opts)
-		return nil
+		return twoFactorAuth(loginResponse.TfaKey, opts)
+	case consts.EMPTY_STRING:
+		// No verification required, the tokens came back with the password login
+		return saveLoginResponse(*loginResponse, opts)
 	default:
 		return fmt.Errorf("unknown login type: %v", loginResponse.LoginType)
 	}
 }
 
+// verifyCodeFlow sends a login code to the account's email, or by SMS to phone accounts, and logs
+// in with the code entered.
+func verifyCodeFlow(opts *types.CliFlags) error {
+	kind, send, channel := emailCodeKind, sendCodeToEmail, "email"
+	if isPhoneAccount(opts.UserAccount) {
+		kind, send, channel = smsCodeKind, sendCodeToPhone, "sms"
+	}
+
+	// Fail before a code is sent that nobody can read
+	if opts.NonInteractive && !hasCodeSource(opts, kind) {
+		return errCodeRequired
+	}
+
+	if err := checkLimits(opts, codeSend, time.Now()); err != nil {
+		return err
+	}
+
+	if err := send(opts); err != nil {
+		recordFailure(opts, err)
+		slog.Error("error sending verification code", "channel", channel, "error", err)
+		return err
+	}
+	recordCodeSent(opts)
+	slog.Info("verification code sent", "account", opts.UserAccount, "channel", channel)
+
+	return newCodeSession(opts, kind, send).run(func(code string) error {
+		return emailCodeLogin(code, opts)
+	})
+}
+
 func sendCodeToEmail(opts *types.CliFlags) error {
 
 	sendCodePayload := types.RequestEmailCodePayload{
@@ -95,6 +170,28 @@ func sendCodeToEmail(opts *types.CliFlags) error {
 	return nil
 }
 
+func sendCodeToPhone(opts *types.CliFlags) error {
+
+	sendCodePayload := types.RequestSMSCodePayload{
+		Phone: opts.UserAccount,
+		Type:  "codeLogin",
+	}
+
+	jsonSendCodePayload, err := json.Marshal(sendCodePayload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sendCodePayload: %v", err)
+	}
+
+	url, err := consts.RegionalURL(consts.SMSCodeURL, opts.UserRegion)
+	if err != nil {
+		return fmt.Errorf("failed to construct smsCodeUrl: %v", err)
+	}
+
+	_, err = httpclient.Request("POST", string(url), jsonSendCodePayload)
+
+	return err
+}
+
 func emailCodeLogin(code string, opts *types.CliFlags) error {
 
 	emailCodePayload := types.EmailCodePayload{
@@ -114,10 +211,10 @@ func emailCodeLogin(code string, opts *types.CliFlags) error {
 
 	emailCodeResponse, err := httpclient.Request("POST", string(url), jsonEmailCodePayload)
 	if err != nil {
-		return err
+		return rejectedCode(err)
 	}
 
-	if err := utils.SaveLoginResponseToFile(*emailCodeResponse, opts.OutputPath); err != nil {
+	if err := saveLoginResponse(*emailCodeResponse, opts); err != nil {
 		return err
 	}
 
@@ -125,11 +222,12 @@ func emailCodeLogin(code string, opts *types.CliFlags) error {
 }
 
 func twoFactorAuth(tfaKey string, opts *types.CliFlags) error {
-	fmt.Print("2FA: Enter your one-time password: ")
-
-	var tfaCode string
-	fmt.Scanln(&tfaCode)
+	return newCodeSession(opts, tfaCodeKind, nil).run(func(tfaCode string) error {
+		return submitTwoFactorCode(tfaKey, tfaCode, opts)
+	})
+}
 
+func submitTwoFactorCode(tfaKey string, tfaCode string, opts *types.CliFlags) error {
 	twoFactorAuthPayload := types.TwoFactorPayload{
 		TFAKey:  tfaKey,
 		TFACode: tfaCode,
@@ -146,10 +244,54 @@ func twoFactorAuth(tfaKey string, opts *types.CliFlags) error {
 	}
 
 	tfaResponse, err := httpclient.CookieRequest("POST", string(url), twoFactorAuthPayloadJSON)
+	if err != nil {
+		return rejectedCode(err)
+	}
 
-	if err := utils.SaveLoginResponseToFile(*tfaResponse, opts.OutputPath); err != nil {
+	if err := saveLoginResponse(*tfaResponse, opts); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// saveLoginResponse records which account and region the tokens belong to and writes them to the auth file.
+// Unusable responses are refused so they never replace a working auth file.
+func saveLoginResponse(loginResponse types.LoginResponse, opts *types.CliFlags) error {
+	loginResponse.Account = opts.UserAccount
+	loginResponse.Region = opts.UserRegion
+
+	if err := validateLoginResponse(loginResponse, time.Now()); err != nil {
+		return keptAuthFile(err, opts.OutputPath)
+	}
+	backupAuthFile(opts)
+
+	if err := utils.SaveLoginResponseToFile(loginResponse, opts.OutputPath); err != nil {
+		return err
+	}
+	recordSuccess(opts)
+
+	if opts.SaveCookies && httpclient.Jar != nil {
+		if err := httpclient.Jar.Save(utils.CookieFilePath(opts.OutputPath)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keptAuthFile notes in err that the auth file already in path was left as it was, if there is one.
+func keptAuthFile(err error, path string) error {
+	if _, statErr := os.Stat(utils.AuthFilePath(path)); statErr != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w, the existing auth file was kept", err)
+}
+
+// backupAuthFile keeps the auth file about to be replaced in the history. A failure only loses the backup.
+func backupAuthFile(opts *types.CliFlags) {
+	if err := history.Backup(opts.OutputPath, opts.HistorySize); err != nil {
+		slog.Warn("the previous auth file was not kept in the history", "error", err)
+	}
+}
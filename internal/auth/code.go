@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/credentials"
+	"github.com/ondrovic/bambulab-authenticator/internal/totp"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// codeKind is the kind of verification code a login step asks for.
+type codeKind string
+
+const (
+	emailCodeKind codeKind = "VerifyCode: Enter the code from your email: "
+	smsCodeKind   codeKind = "VerifyCode: Enter the code sent to your phone: "
+	tfaCodeKind   codeKind = "2FA: Enter your one-time password: "
+)
+
+// errCodeRequired is returned in non-interactive mode when a code is needed but cannot be obtained without a prompt.
+var errCodeRequired = types.NewCodedError(types.ErrCodeCodeRequired,
+	errors.New("a verification code is required but no non-interactive source is configured (use --code, --code-command, or --totp-secret for 2FA)"))
+
+// hasCodeSource reports whether a code of the given kind can be obtained without prompting.
+func hasCodeSource(opts *types.CliFlags, kind codeKind) bool {
+	if opts.Code != consts.EMPTY_STRING || opts.CodeCommand != consts.EMPTY_STRING {
+		return true
+	}
+
+	return kind == tfaCodeKind && opts.TOTPSecret != consts.EMPTY_STRING
+}
+
+// verificationCode returns the code for a login step from, in order, the code flag, the code
+// command, the TOTP secret (2FA only) or an interactive prompt. Prompting is refused in non-interactive mode.
+func verificationCode(opts *types.CliFlags, kind codeKind) (string, error) {
+	if opts.Code != consts.EMPTY_STRING {
+		return opts.Code, nil
+	}
+
+	if opts.CodeCommand != consts.EMPTY_STRING {
+		return credentials.RunCommand(opts.CodeCommand)
+	}
+
+	if kind == tfaCodeKind && opts.TOTPSecret != consts.EMPTY_STRING {
+		code, err := totp.Generate(opts.TOTPSecret, time.Now())
+		if err != nil {
+			return consts.EMPTY_STRING, fmt.Errorf("failed to generate one-time password: %v", err)
+		}
+		return code, nil
+	}
+
+	if opts.NonInteractive {
+		return consts.EMPTY_STRING, errCodeRequired
+	}
+
+	return utils.Prompt(string(kind)), nil
+}
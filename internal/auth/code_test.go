@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestVerificationCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name         string
+		opts         types.CliFlags
+		kind         codeKind
+		expectedCode string
+		expectedErr  error
+	}{
+		{
+			name:         "Code flag",
+			opts:         types.CliFlags{Code: "654321", NonInteractive: true},
+			kind:         emailCodeKind,
+			expectedCode: "654321",
+		},
+		{
+			name: "TOTP secret",
+			opts: types.CliFlags{TOTPSecret: secret, NonInteractive: true},
+			kind: tfaCodeKind,
+		},
+		{
+			name:        "TOTP secret is not used for email codes",
+			opts:        types.CliFlags{TOTPSecret: secret, NonInteractive: true},
+			kind:        emailCodeKind,
+			expectedErr: errCodeRequired,
+		},
+		{
+			name:        "Non-interactive without a source",
+			opts:        types.CliFlags{NonInteractive: true},
+			kind:        tfaCodeKind,
+			expectedErr: errCodeRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := verificationCode(&tt.opts, tt.kind)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("verificationCode() error = %v, expected %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+			if len(code) != 6 {
+				t.Errorf("verificationCode() = %q, expected a 6 digit code", code)
+			}
+			if tt.expectedCode != "" && code != tt.expectedCode {
+				t.Errorf("verificationCode() = %q, expected %q", code, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestProcessLoginTypeNonInteractiveFailsBeforeSendingEmail(t *testing.T) {
+	opts := &types.CliFlags{
+		UserAccount:    "test@example.com",
+		UserRegion:     "us",
+		NonInteractive: true,
+	}
+
+	// No HTTP client is configured, so any request would panic
+	err := processLoginType(&types.LoginResponse{LoginType: "verifyCode"}, opts)
+
+	if types.ErrorCode(err) != types.ErrCodeCodeRequired {
+		t.Errorf("processLoginType() error = %v, expected code %v", err, types.ErrCodeCodeRequired)
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/cookies"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// Cookie domains of the web sessions, and the region saved when none is given.
+const (
+	cookieDomain      = "bambulab.com"
+	chinaCookieDomain = "bambulab.cn"
+	chinaRegion       = "china"
+	globalRegion      = "global"
+)
+
+// ImportCookies turns the cookies of a logged-in bambulab.com browser session into a saved session.
+// The tokens are checked against the profile endpoint, refreshing them if they have expired, before
+// the auth file is written to opts.OutputPath. When opts.UserRegion is empty it is inferred from the
+// cookie domain.
+func ImportCookies(browserCookies []*http.Cookie, opts *types.CliFlags) (*types.LoginResponse, error) {
+
+	tokens, tokenCookies, err := cookieTokens(browserCookies, opts)
+	if err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, err)
+	}
+
+	// Cookie expiry dates are absolute, unlike expiresIn which counts from when the cookie was set
+	tokens.StampExpiry(time.Now())
+	if cookie := tokenCookies["token"]; cookie != nil && !cookie.Expires.IsZero() {
+		tokens.ExpiresAt = cookie.Expires.Unix()
+	}
+	if cookie := tokenCookies["refreshToken"]; cookie != nil && !cookie.Expires.IsZero() {
+		tokens.RefreshExpiresAt = cookie.Expires.Unix()
+	}
+
+	url, err := consts.RegionalURL(consts.RefreshTokenURL, opts.UserRegion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct refreshTokenUrl: %v", err)
+	}
+
+	if err := httpclient.InitRefreshingClient(*tokens, string(url), nil); err != nil {
+		return nil, err
+	}
+
+	profile, err := FetchProfile(opts.UserRegion)
+	if err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, fmt.Errorf("the imported session was rejected: %v", err))
+	}
+
+	validated, err := CurrentTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	validated.Account = profile.Account
+	if validated.Account == consts.EMPTY_STRING {
+		validated.Account = opts.UserAccount
+	}
+	validated.Region = opts.UserRegion
+
+	slog.Debug("imported session", "account", validated.Account, "region", validated.Region)
+
+	if err := validateLoginResponse(*validated, time.Now()); err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, keptAuthFile(err, opts.OutputPath))
+	}
+	backupAuthFile(opts)
+
+	if err := utils.SaveLoginResponseToFile(*validated, opts.OutputPath); err != nil {
+		return nil, err
+	}
+
+	return validated, nil
+}
+
+// cookieTokens extracts the tokens from the cookies of the region's domain, returning the cookies
+// they came from by name.
+func cookieTokens(browserCookies []*http.Cookie, opts *types.CliFlags) (*types.LoginResponse, map[string]*http.Cookie, error) {
+
+	domain := cookieDomain
+	switch {
+	case strings.EqualFold(opts.UserRegion, chinaRegion):
+		domain = chinaCookieDomain
+	case opts.UserRegion == consts.EMPTY_STRING:
+		opts.UserRegion = globalRegion
+		if !hasTokenCookie(cookies.FilterDomain(browserCookies, cookieDomain)) && hasTokenCookie(cookies.FilterDomain(browserCookies, chinaCookieDomain)) {
+			domain = chinaCookieDomain
+			opts.UserRegion = chinaRegion
+		}
+	}
+
+	filtered := cookies.FilterDomain(browserCookies, domain)
+
+	tokens, err := httpclient.MapCookiesToResponse(filtered)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tokens.AccessToken == consts.EMPTY_STRING {
+		return nil, nil, fmt.Errorf("no token cookie found for %v, export the cookies while logged in", domain)
+	}
+
+	byName := map[string]*http.Cookie{}
+	for _, cookie := range filtered {
+		byName[cookie.Name] = cookie
+	}
+
+	return tokens, byName, nil
+}
+
+func hasTokenCookie(browserCookies []*http.Cookie) bool {
+	for _, cookie := range browserCookies {
+		if cookie.Name == "token" && cookie.Value != consts.EMPTY_STRING {
+			return true
+		}
+	}
+
+	return false
+}
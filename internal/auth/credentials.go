@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/credentials"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// resolvePassword returns the account password from, in order, the password flag, the password
+// command, the credential helper or an interactive prompt.
+func resolvePassword(opts *types.CliFlags) (string, error) {
+	if opts.UserPassword != consts.EMPTY_STRING {
+		return opts.UserPassword, nil
+	}
+
+	if opts.PasswordCommand != consts.EMPTY_STRING {
+		return credentials.RunCommand(opts.PasswordCommand)
+	}
+
+	if opts.CredentialHelper != consts.EMPTY_STRING {
+		credential, err := helperCredential(opts, consts.EMPTY_STRING)
+		if err != nil {
+			return consts.EMPTY_STRING, err
+		}
+
+		found, err := credentials.Helper{Command: opts.CredentialHelper}.Get(credential)
+		if err != nil {
+			return consts.EMPTY_STRING, err
+		}
+		if found.Password != consts.EMPTY_STRING {
+			return found.Password, nil
+		}
+	}
+
+	if opts.NonInteractive {
+		return consts.EMPTY_STRING, types.NewCodedError(types.ErrCodeInvalidArgument,
+			errors.New("a password is required (use --user-password, --password-command or --credential-helper)"))
+	}
+
+	return utils.PromptSecret("Password: ")
+}
+
+// helperCredential describes the account to the credential helper the way git would describe a remote.
+func helperCredential(opts *types.CliFlags, password string) (credentials.Credential, error) {
+	referer, err := consts.RegionalURL(consts.RefererURL, opts.UserRegion)
+	if err != nil {
+		return credentials.Credential{}, fmt.Errorf("failed to construct refererUrl: %v", err)
+	}
+
+	parsed, err := url.Parse(string(referer))
+	if err != nil {
+		return credentials.Credential{}, fmt.Errorf("failed to parse refererUrl: %v", err)
+	}
+
+	return credentials.Credential{
+		Protocol: parsed.Scheme,
+		Host:     parsed.Host,
+		Username: opts.UserAccount,
+		Password: password,
+	}, nil
+}
+
+// notifyCredentialHelper reports the login outcome to the credential helper, if one is configured,
+// so it can store a working password or forget a rejected one. Failures are only logged.
+func notifyCredentialHelper(opts *types.CliFlags, password string, accepted bool) {
+	if opts.CredentialHelper == consts.EMPTY_STRING {
+		return
+	}
+
+	credential, err := helperCredential(opts, password)
+	if err != nil {
+		slog.Warn("failed to describe credential", "error", err)
+		return
+	}
+
+	helper := credentials.Helper{Command: opts.CredentialHelper}
+	if accepted {
+		err = helper.Store(credential)
+	} else {
+		err = helper.Erase(credential)
+	}
+	if err != nil {
+		slog.Warn("credential helper failed", "error", err)
+	}
+}
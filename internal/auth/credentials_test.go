@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestResolvePassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper commands use sh")
+	}
+
+	tests := []struct {
+		name      string
+		opts      types.CliFlags
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "Password flag wins",
+			opts:     types.CliFlags{UserPassword: "flag", PasswordCommand: "echo command"},
+			expected: "flag",
+		},
+		{
+			name:     "Password command",
+			opts:     types.CliFlags{PasswordCommand: "echo command"},
+			expected: "command",
+		},
+		{
+			name: "Credential helper",
+			opts: types.CliFlags{
+				UserAccount:      "user@example.com",
+				UserRegion:       "us",
+				CredentialHelper: `f() { cat >/dev/null; echo password=helper; }; f`,
+			},
+			expected: "helper",
+		},
+		{
+			name: "Credential helper without a match",
+			opts: types.CliFlags{
+				UserAccount:      "user@example.com",
+				UserRegion:       "us",
+				CredentialHelper: `f() { cat >/dev/null; }; f`,
+				NonInteractive:   true,
+			},
+			expectErr: true,
+		},
+		{
+			name:      "Non-interactive without a source",
+			opts:      types.CliFlags{NonInteractive: true},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePassword(&tt.opts)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("resolvePassword() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if got != tt.expected {
+				t.Errorf("resolvePassword() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHelperCredential(t *testing.T) {
+	credential, err := helperCredential(&types.CliFlags{UserAccount: "user@example.com", UserRegion: "china"}, "secret")
+	if err != nil {
+		t.Fatalf("helperCredential() unexpected error: %v", err)
+	}
+
+	if credential.Protocol != "https" || credential.Host != "bambulab.cn" || credential.Username != "user@example.com" || credential.Password != "secret" {
+		t.Errorf("helperCredential() = %+v", credential)
+	}
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// FetchDevices returns the printers bound to the account the current client is authenticated as,
+// including their serials and LAN access codes.
+func FetchDevices(region string) ([]types.Device, error) {
+
+	url, err := consts.RegionalURL(consts.BindURL, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct bindUrl: %v", err)
+	}
+
+	var response types.DevicesResponse
+	if err := httpclient.RequestJSON("GET", string(url), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch devices: %v", err)
+	}
+
+	return response.Devices, nil
+}
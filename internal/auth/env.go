@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// Environment variable names exposed to child processes and shells.
+const (
+	EnvToken          = "BAMBU_TOKEN"
+	EnvRefreshToken   = "BAMBU_REFRESH_TOKEN"
+	EnvTokenExpiresAt = "BAMBU_TOKEN_EXPIRES_AT"
+	EnvUID            = "BAMBU_UID"
+	EnvRegion         = "BAMBU_REGION"
+	EnvMQTTHost       = "BAMBU_MQTT_HOST"
+	EnvMQTTPort       = "BAMBU_MQTT_PORT"
+	EnvMQTTUsername   = "BAMBU_MQTT_USERNAME"
+	EnvMQTTPassword   = "BAMBU_MQTT_PASSWORD"
+)
+
+// mqttUsernamePrefix is prepended to the uid to form the cloud MQTT username.
+const mqttUsernamePrefix = "u_"
+
+// TokenEnvironment describes the tokens as environment variables without contacting the API.
+func TokenEnvironment(tokens *types.LoginResponse, region string) []types.EnvVar {
+	env := []types.EnvVar{
+		{Name: EnvToken, Value: tokens.AccessToken},
+		{Name: EnvRefreshToken, Value: tokens.RefreshToken},
+	}
+
+	if tokens.ExpiresAt != 0 {
+		env = append(env, types.EnvVar{Name: EnvTokenExpiresAt, Value: strconv.FormatInt(tokens.ExpiresAt, 10)})
+	}
+
+	return append(env, types.EnvVar{Name: EnvRegion, Value: region})
+}
+
+// SessionEnvironment describes the loaded session as environment variables. The profile is
+// fetched first, which refreshes an expired token, to learn the uid used for MQTT; when that
+// fails the uid based variables are omitted.
+func SessionEnvironment(region string) ([]types.EnvVar, error) {
+	profile, profileErr := FetchProfile(region)
+
+	tokens, err := CurrentTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	env := append(TokenEnvironment(tokens, region),
+		types.EnvVar{Name: EnvMQTTHost, Value: consts.MQTTBroker(region)},
+		types.EnvVar{Name: EnvMQTTPort, Value: consts.MQTTPort},
+	)
+
+	if profileErr != nil {
+		slog.Warn("could not determine uid, MQTT username omitted", "error", profileErr)
+		return env, nil
+	}
+
+	uid := profile.UID.String()
+	return append(env,
+		types.EnvVar{Name: EnvUID, Value: uid},
+		types.EnvVar{Name: EnvMQTTUsername, Value: mqttUsernamePrefix + uid},
+		types.EnvVar{Name: EnvMQTTPassword, Value: tokens.AccessToken},
+	), nil
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// LimitsFileName is the name of the file inside the state directory that remembers login
+// attempts across runs.
+const LimitsFileName = "limits.json"
+
+const (
+	// maxFailedAttempts is how many rejected passwords or codes in a row are allowed before
+	// further attempts wait for failureLockout.
+	maxFailedAttempts = 5
+	failureLockout    = 15 * time.Minute
+	// defaultRateLimitWait is assumed when the server rate limits without a Retry-After header.
+	defaultRateLimitWait = time.Minute
+)
+
+const (
+	// limitsLockTimeout bounds the wait for another run to release the limits file.
+	limitsLockTimeout = 10 * time.Second
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+// accountLimits is what is remembered about one account's recent login attempts.
+type accountLimits struct {
+	LastCodeSentAt time.Time `json:"lastCodeSentAt"`
+	FailedAttempts int       `json:"failedAttempts"`
+	LastFailureAt  time.Time `json:"lastFailureAt"`
+	// CooldownUntil is when the server allows requests again after rate limiting them.
+	CooldownUntil time.Time `json:"cooldownUntil"`
+}
+
+// limitAction is the request a limit check is made for.
+type limitAction int
+
+const (
+	passwordAttempt limitAction = iota
+	codeSend
+)
+
+// DefaultStateDir returns the per-user directory the login limits are kept in.
+func DefaultStateDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return consts.EMPTY_STRING
+	}
+
+	return filepath.Join(dir, "bambulab-authenticator")
+}
+
+func limitsKey(opts *types.CliFlags) string {
+	return strings.ToLower(opts.UserRegion) + "/" + strings.ToLower(opts.UserAccount)
+}
+
+func readLimits(dir string) (map[string]accountLimits, error) {
+	limits := map[string]accountLimits{}
+
+	data, err := os.ReadFile(filepath.Join(dir, LimitsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return limits, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login limits: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("failed to parse login limits: %v", err)
+	}
+
+	return limits, nil
+}
+
+func writeLimits(dir string, limits map[string]accountLimits) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(limits, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal login limits: %v", err)
+	}
+
+	// Write through a temporary file so concurrent runs never read a partial file
+	tmp, err := os.CreateTemp(dir, LimitsFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write login limits: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write login limits: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write login limits: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, LimitsFileName)); err != nil {
+		return fmt.Errorf("failed to write login limits: %v", err)
+	}
+
+	return nil
+}
+
+// lockLimits takes an advisory lock on the lock file next to the limits file, so runs in parallel do
+// not overwrite each other's updates. The system drops the lock when its process dies, so a lock file
+// left behind by a crashed run never blocks. The file itself stays. The returned function releases it.
+func lockLimits(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	path := filepath.Join(dir, LimitsFileName+".lock")
+	lock, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open login limits lock: %v", err)
+	}
+
+	deadline := time.Now().Add(limitsLockTimeout)
+	for {
+		locked, err := tryLockFile(lock)
+		if err != nil {
+			lock.Close()
+			return nil, fmt.Errorf("failed to lock login limits: %v", err)
+		}
+		if locked {
+			return func() {
+				unlockFile(lock)
+				lock.Close()
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			lock.Close()
+			return nil, fmt.Errorf("timed out waiting for the login limits lock %v", path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// updateLimits applies change to the account's limits and saves them, holding the lock in between.
+// Tracking is best effort: it is skipped without a state directory, and failures are only logged.
+func updateLimits(opts *types.CliFlags, change func(limits *accountLimits)) {
+	if opts.StateDir == consts.EMPTY_STRING {
+		return
+	}
+
+	unlock, err := lockLimits(opts.StateDir)
+	if err != nil {
+		slog.Warn("login limits are not tracked", "error", err)
+		return
+	}
+	defer unlock()
+
+	all, err := readLimits(opts.StateDir)
+	if err != nil {
+		slog.Warn("login limits are not tracked", "error", err)
+		return
+	}
+
+	key := limitsKey(opts)
+	limits := all[key]
+	change(&limits)
+
+	if limits == (accountLimits{}) {
+		delete(all, key)
+	} else {
+		all[key] = limits
+	}
+
+	if err := writeLimits(opts.StateDir, all); err != nil {
+		slog.Warn("login limits are not tracked", "error", err)
+	}
+}
+
+// checkLimits refuses an action that earlier runs showed would be rate limited or risk locking the
+// account, unless opts.Force is set.
+func checkLimits(opts *types.CliFlags, action limitAction, now time.Time) error {
+	if opts.StateDir == consts.EMPTY_STRING {
+		return nil
+	}
+
+	all, err := readLimits(opts.StateDir)
+	if err != nil {
+		slog.Warn("login limits are not checked", "error", err)
+		return nil
+	}
+	limits := all[limitsKey(opts)]
+
+	var until time.Time
+	var reason string
+	switch {
+	case now.Before(limits.CooldownUntil):
+		until, reason = limits.CooldownUntil, "the server rate limited the last request"
+	case limits.FailedAttempts >= maxFailedAttempts && now.Before(limits.LastFailureAt.Add(failureLockout)):
+		until = limits.LastFailureAt.Add(failureLockout)
+		reason = fmt.Sprintf("%d failed attempts in a row", limits.FailedAttempts)
+	case action == codeSend && now.Before(limits.LastCodeSentAt.Add(opts.ResendCooldown)):
+		until, reason = limits.LastCodeSentAt.Add(opts.ResendCooldown), "a code was sent recently"
+	default:
+		return nil
+	}
+
+	wait := until.Sub(now).Round(time.Second)
+	if opts.Force {
+		slog.Warn("ignoring login limits", "reason", reason, "wait", wait)
+		return nil
+	}
+
+	return types.NewCodedError(types.ErrCodeRateLimited,
+		fmt.Errorf("not trying to log in to %v: %s, try again in %v or pass --force", opts.UserAccount, reason, wait))
+}
+
+// recordCodeSent remembers when a verification code was last sent.
+func recordCodeSent(opts *types.CliFlags) {
+	updateLimits(opts, func(limits *accountLimits) {
+		limits.LastCodeSentAt = time.Now()
+	})
+}
+
+// recordFailure remembers a rejected password or code, or the cooldown of a rate limited request.
+// Other errors are not the account's fault and are not counted.
+func recordFailure(opts *types.CliFlags, err error) {
+	var statusErr *httpclient.StatusError
+	if !errors.Is(err, errCodeRejected) && !errors.As(err, &statusErr) {
+		return
+	}
+
+	updateLimits(opts, func(limits *accountLimits) {
+		now := time.Now()
+		switch {
+		case statusErr != nil && statusErr.RetryAfter > 0:
+			limits.CooldownUntil = now.Add(statusErr.RetryAfter)
+		case statusErr != nil && statusErr.StatusCode == http.StatusTooManyRequests:
+			limits.CooldownUntil = now.Add(defaultRateLimitWait)
+		case statusErr != nil && !statusErr.Rejected():
+			// Server errors say nothing about the credentials
+		default:
+			countFailure(limits, now)
+		}
+	})
+}
+
+func countFailure(limits *accountLimits, now time.Time) {
+	limits.FailedAttempts++
+	limits.LastFailureAt = now
+}
+
+// recordSuccess clears the failed attempts after a login.
+func recordSuccess(opts *types.CliFlags) {
+	updateLimits(opts, func(limits *accountLimits) {
+		limits.FailedAttempts = 0
+		limits.LastFailureAt = time.Time{}
+		limits.CooldownUntil = time.Time{}
+	})
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestCheckLimits(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		limits    accountLimits
+		action    limitAction
+		force     bool
+		expectErr bool
+	}{
+		{name: "Nothing recorded", action: passwordAttempt},
+		{name: "Server cooldown", limits: accountLimits{CooldownUntil: now.Add(time.Minute)}, action: passwordAttempt, expectErr: true},
+		{name: "Server cooldown over", limits: accountLimits{CooldownUntil: now.Add(-time.Second)}, action: passwordAttempt},
+		{name: "Server cooldown forced", limits: accountLimits{CooldownUntil: now.Add(time.Minute)}, action: passwordAttempt, force: true},
+		{name: "Too many failures", limits: accountLimits{FailedAttempts: maxFailedAttempts, LastFailureAt: now.Add(-time.Minute)}, action: codeSend, expectErr: true},
+		{name: "Failures below the limit", limits: accountLimits{FailedAttempts: maxFailedAttempts - 1, LastFailureAt: now}, action: passwordAttempt},
+		{name: "Lockout over", limits: accountLimits{FailedAttempts: maxFailedAttempts, LastFailureAt: now.Add(-failureLockout)}, action: passwordAttempt},
+		{name: "Code sent recently", limits: accountLimits{LastCodeSentAt: now.Add(-30 * time.Second)}, action: codeSend, expectErr: true},
+		{name: "Code sent recently does not block passwords", limits: accountLimits{LastCodeSentAt: now.Add(-30 * time.Second)}, action: passwordAttempt},
+		{name: "Code sent long ago", limits: accountLimits{LastCodeSentAt: now.Add(-2 * time.Minute)}, action: codeSend},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &types.CliFlags{
+				UserAccount:    "user@example.com",
+				UserRegion:     "us",
+				ResendCooldown: time.Minute,
+				StateDir:       t.TempDir(),
+				Force:          tt.force,
+			}
+			if err := writeLimits(opts.StateDir, map[string]accountLimits{limitsKey(opts): tt.limits}); err != nil {
+				t.Fatalf("writeLimits() error = %v", err)
+			}
+
+			err := checkLimits(opts, tt.action, now)
+			if tt.expectErr {
+				if types.ErrorCode(err) != types.ErrCodeRateLimited {
+					t.Fatalf("checkLimits() = %v, expected a rate_limited error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkLimits() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordSuccessClearsFailures(t *testing.T) {
+	opts := &types.CliFlags{UserAccount: "user@example.com", UserRegion: "us", StateDir: t.TempDir()}
+
+	for range maxFailedAttempts {
+		recordFailure(opts, errCodeRejected)
+	}
+	if err := checkLimits(opts, passwordAttempt, time.Now()); err == nil {
+		t.Fatal("checkLimits() allowed an attempt after too many failures")
+	}
+
+	recordSuccess(opts)
+
+	limits, err := readLimits(opts.StateDir)
+	if err != nil {
+		t.Fatalf("readLimits() error = %v", err)
+	}
+	if _, ok := limits[limitsKey(opts)]; ok {
+		t.Errorf("limits = %+v, expected the account to be cleared", limits)
+	}
+}
+
+func TestUpdateLimitsInParallel(t *testing.T) {
+	opts := &types.CliFlags{UserAccount: "user@example.com", UserRegion: "us", StateDir: t.TempDir()}
+
+	const runs, failures = 8, 10
+	var wg sync.WaitGroup
+	for range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range failures {
+				recordFailure(opts, errCodeRejected)
+			}
+		}()
+	}
+	wg.Wait()
+
+	limits, err := readLimits(opts.StateDir)
+	if err != nil {
+		t.Fatalf("readLimits() error = %v", err)
+	}
+	if got := limits[limitsKey(opts)].FailedAttempts; got != runs*failures {
+		t.Errorf("FailedAttempts = %d, expected %d, updates were lost", got, runs*failures)
+	}
+}
+
+func TestUpdateLimitsWithStaleLockFile(t *testing.T) {
+	opts := &types.CliFlags{UserAccount: "user@example.com", UserRegion: "us", StateDir: t.TempDir()}
+
+	// A crashed run leaves the lock file behind, but not the lock
+	path := filepath.Join(opts.StateDir, LimitsFileName+".lock")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	const runs = 8
+	var wg sync.WaitGroup
+	for range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recordFailure(opts, errCodeRejected)
+		}()
+	}
+	wg.Wait()
+
+	limits, err := readLimits(opts.StateDir)
+	if err != nil {
+		t.Fatalf("readLimits() error = %v", err)
+	}
+	if got := limits[limitsKey(opts)].FailedAttempts; got != runs {
+		t.Errorf("FailedAttempts = %d, expected %d, updates were lost", got, runs)
+	}
+}
+
+func TestLockLimitsWaitsForHolder(t *testing.T) {
+	dir := t.TempDir()
+
+	unlock, err := lockLimits(dir)
+	if err != nil {
+		t.Fatalf("lockLimits() error = %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := lockLimits(dir)
+		if err != nil {
+			t.Errorf("lockLimits() error = %v", err)
+		} else {
+			second()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lockLimits() returned while the lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(limitsLockTimeout):
+		t.Fatal("lockLimits() did not return after the lock was released")
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// FetchProfile returns the profile of the account the current client is authenticated as.
+func FetchProfile(region string) (*types.Profile, error) {
+
+	url, err := consts.RegionalURL(consts.ProfileURL, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct profileUrl: %v", err)
+	}
+
+	var profile types.Profile
+	if err := httpclient.RequestJSON("GET", string(url), nil, &profile); err != nil {
+		return nil, fmt.Errorf("failed to fetch profile: %v", err)
+	}
+
+	return &profile, nil
+}
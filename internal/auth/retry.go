@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// resendAnswer is typed at a code prompt to have a new code sent.
+const resendAnswer = "r"
+
+// errCodeRejected is wrapped by the error returned when the server refuses a verification code.
+var errCodeRejected = errors.New("the verification code was rejected")
+
+// countdown shows the remaining wait on w, one line rewritten every second. Replaced in tests.
+var countdown = func(w io.Writer, wait time.Duration) {
+	for remaining := wait.Round(time.Second); remaining > 0; remaining -= time.Second {
+		fmt.Fprintf(w, "\rA new code can be sent in %ds ", int(remaining.Seconds()))
+		time.Sleep(time.Second)
+	}
+	fmt.Fprint(w, "\r\033[K")
+}
+
+// rejectedCode turns a refused code submission into an error wrapping errCodeRejected.
+func rejectedCode(err error) error {
+	var statusErr *httpclient.StatusError
+	if errors.As(err, &statusErr) && statusErr.Rejected() {
+		if statusErr.Message != consts.EMPTY_STRING {
+			return fmt.Errorf("%w: %s", errCodeRejected, statusErr.Message)
+		}
+		return errCodeRejected
+	}
+
+	return err
+}
+
+// codeSession submits the verification code of one login step. When the code is typed at a prompt,
+// a rejected code is asked for again up to opts.CodeAttempts times and a new one can be sent on request.
+type codeSession struct {
+	opts *types.CliFlags
+	kind codeKind
+	// send delivers a new code, nil when the step cannot resend one (2FA).
+	send     func(opts *types.CliFlags) error
+	sentAt   time.Time
+	cooldown time.Duration
+}
+
+func newCodeSession(opts *types.CliFlags, kind codeKind, send func(opts *types.CliFlags) error) *codeSession {
+	return &codeSession{
+		opts:     opts,
+		kind:     kind,
+		send:     send,
+		sentAt:   time.Now(),
+		cooldown: opts.ResendCooldown,
+	}
+}
+
+// prompting reports whether codes are typed by the user, the only case where asking again can help.
+func (c *codeSession) prompting() bool {
+	return !hasCodeSource(c.opts, c.kind) && !c.opts.NonInteractive
+}
+
+// run asks for codes and submits them until one is accepted, a submission fails for another reason,
+// or the attempts are used up.
+func (c *codeSession) run(submit func(code string) error) error {
+	attempts := c.opts.CodeAttempts
+	if attempts < 1 || !c.prompting() {
+		attempts = 1
+	}
+
+	for remaining := attempts; ; {
+		code, err := c.code()
+		if err != nil {
+			return err
+		}
+
+		err = submit(code)
+		if err != nil {
+			recordFailure(c.opts, err)
+		}
+		if !errors.Is(err, errCodeRejected) {
+			return err
+		}
+
+		remaining--
+		if remaining == 0 {
+			if attempts > 1 {
+				return types.NewCodedError(types.ErrCodeLoginFailed, fmt.Errorf("%v, no attempts left", err))
+			}
+			return types.NewCodedError(types.ErrCodeLoginFailed, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Incorrect code, %d %s left\n", remaining, plural(remaining, "attempt", "attempts"))
+	}
+}
+
+// code returns the next code, handling resend requests typed at the prompt.
+func (c *codeSession) code() (string, error) {
+	if c.send == nil || !c.prompting() {
+		return verificationCode(c.opts, c.kind)
+	}
+
+	message := strings.TrimSuffix(string(c.kind), ": ") + fmt.Sprintf(" (%s to resend): ", resendAnswer)
+	for {
+		answer := strings.TrimSpace(utils.Prompt(message))
+		if !strings.EqualFold(answer, resendAnswer) {
+			return answer, nil
+		}
+
+		if err := c.resend(); err != nil {
+			return consts.EMPTY_STRING, err
+		}
+	}
+}
+
+// resend waits out the cooldown since the last code was sent, showing a countdown, and sends a new one.
+func (c *codeSession) resend() error {
+	if wait := c.cooldown - time.Since(c.sentAt); wait > 0 {
+		countdown(os.Stderr, wait)
+	}
+
+	if err := c.send(c.opts); err != nil {
+		recordFailure(c.opts, err)
+		var statusErr *httpclient.StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			// The server sets the real cooldown, keep it for the next request
+			fmt.Fprintf(os.Stderr, "No code was sent, the server asks to wait %v\n", statusErr.RetryAfter)
+			c.sentAt = time.Now()
+			c.cooldown = max(c.cooldown, statusErr.RetryAfter)
+			return nil
+		}
+		return err
+	}
+
+	c.sentAt = time.Now()
+	recordCodeSent(c.opts)
+	fmt.Fprintln(os.Stderr, "A new code was sent")
+
+	return nil
+}
+
+func plural(n int, singular string, multiple string) string {
+	if n == 1 {
+		return singular
+	}
+
+	return multiple
+}
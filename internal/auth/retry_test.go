@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestCodeSessionResendWaitsForCooldown(t *testing.T) {
+	var waited time.Duration
+	original := countdown
+	countdown = func(_ io.Writer, wait time.Duration) { waited = wait }
+	t.Cleanup(func() { countdown = original })
+
+	sent := 0
+	session := newCodeSession(&types.CliFlags{ResendCooldown: time.Minute}, emailCodeKind, func(*types.CliFlags) error {
+		sent++
+		return nil
+	})
+
+	if err := session.resend(); err != nil {
+		t.Fatalf("resend() error = %v", err)
+	}
+	if waited <= 50*time.Second || waited > time.Minute {
+		t.Errorf("resend() waited %v, expected close to the one minute cooldown", waited)
+	}
+	if sent != 1 {
+		t.Errorf("resend() sent %d codes, expected 1", sent)
+	}
+}
+
+func TestCodeSessionResendKeepsServerCooldown(t *testing.T) {
+	original := countdown
+	countdown = func(io.Writer, time.Duration) {}
+	t.Cleanup(func() { countdown = original })
+
+	session := newCodeSession(&types.CliFlags{}, emailCodeKind, func(*types.CliFlags) error {
+		return &httpclient.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 90 * time.Second}
+	})
+
+	if err := session.resend(); err != nil {
+		t.Fatalf("resend() error = %v, a refused resend should return to the prompt", err)
+	}
+	if session.cooldown != 90*time.Second {
+		t.Errorf("cooldown = %v, expected the server's 1m30s", session.cooldown)
+	}
+}
+
+func TestRejectedCode(t *testing.T) {
+	rejected := rejectedCode(&httpclient.StatusError{StatusCode: http.StatusBadRequest, Message: "Incorrect code"})
+	if !errors.Is(rejected, errCodeRejected) {
+		t.Errorf("rejectedCode() = %v, expected errCodeRejected", rejected)
+	}
+
+	limited := &httpclient.StatusError{StatusCode: http.StatusTooManyRequests}
+	if errors.Is(rejectedCode(limited), errCodeRejected) {
+		t.Error("rejectedCode() treated a rate limit as a rejected code")
+	}
+}
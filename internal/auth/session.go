@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// LoadSession reads the saved auth file from opts.OutputPath and initializes the HTTP client
+// so every API call carries the access token and refreshes it when needed. Refreshed tokens
+// are written back to the same auth file. Cookies saved next to it are loaded into the session
+// jar and kept up to date.
+func LoadSession(opts *types.CliFlags) (*types.LoginResponse, error) {
+	save := func(refreshed types.LoginResponse) error {
+		if err := validateLoginResponse(refreshed, time.Now()); err != nil {
+			return keptAuthFile(err, opts.OutputPath)
+		}
+		if err := utils.SaveLoginResponseToFile(refreshed, opts.OutputPath); err != nil {
+			return err
+		}
+		return saveCookies(opts.OutputPath)
+	}
+
+	return loadSession(opts, save)
+}
+
+// LoadEphemeralSession behaves like LoadSession but keeps refreshed tokens in memory only,
+// leaving the auth file untouched.
+func LoadEphemeralSession(opts *types.CliFlags) (*types.LoginResponse, error) {
+	return loadSession(opts, nil)
+}
+
+func loadSession(opts *types.CliFlags, save httpclient.TokenSaver) (*types.LoginResponse, error) {
+
+	tokens, err := utils.LoadLoginResponseFromFile(opts.OutputPath)
+	if err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, fmt.Errorf("failed to load saved session: %v", err))
+	}
+
+	// The saved region is used unless one was given explicitly
+	if opts.UserRegion == consts.EMPTY_STRING {
+		opts.UserRegion = tokens.Region
+	}
+
+	url, err := consts.RegionalURL(consts.RefreshTokenURL, opts.UserRegion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct refreshTokenUrl: %v", err)
+	}
+
+	if err := httpclient.InitRefreshingClient(*tokens, string(url), save); err != nil {
+		return nil, err
+	}
+	loadCookies(opts.OutputPath)
+
+	return tokens, nil
+}
+
+// CurrentTokens returns the tokens of the loaded session, refreshed first when they are about to expire.
+func CurrentTokens() (*types.LoginResponse, error) {
+	tokens, err := httpclient.ActiveTokens()
+	if err != nil {
+		return nil, types.NewCodedError(types.ErrCodeSessionInvalid, err)
+	}
+
+	return tokens, nil
+}
+
+// loadCookies restores the cookies saved next to the auth file into the session jar, if any.
+func loadCookies(path string) {
+	cookieFile := utils.CookieFilePath(path)
+	if httpclient.Jar == nil {
+		return
+	}
+	if _, err := os.Stat(cookieFile); err != nil {
+		return
+	}
+
+	if err := httpclient.Jar.Load(cookieFile); err != nil {
+		slog.Warn("saved cookies are not used", "path", cookieFile, "error", err)
+	}
+}
+
+// saveCookies updates the cookie file next to the auth file, when one was saved before.
+func saveCookies(path string) error {
+	cookieFile := utils.CookieFilePath(path)
+	if httpclient.Jar == nil {
+		return nil
+	}
+	if _, err := os.Stat(cookieFile); err != nil {
+		return nil
+	}
+
+	return httpclient.Jar.Save(cookieFile)
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/jwt"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// minTokenLength is the shortest token accepted as plausible, real tokens are far longer.
+const minTokenLength = 16
+
+// validateLoginResponse checks that a login result can be saved: both tokens present and
+// plausible, and no expiry negative or already past. Every problem found is listed in the error.
+func validateLoginResponse(resp types.LoginResponse, now time.Time) error {
+	var problems []string
+
+	if resp.AccessToken == consts.EMPTY_STRING {
+		problems = append(problems, "access token missing")
+	} else if problem := tokenProblem(resp.AccessToken); problem != consts.EMPTY_STRING {
+		problems = append(problems, "access token "+problem)
+	} else if strings.Count(resp.AccessToken, ".") == 2 {
+		// JWT-shaped tokens must at least decode
+		if _, err := jwt.Decode(resp.AccessToken); err != nil {
+			problems = append(problems, fmt.Sprintf("access token is not a valid JWT (%v)", err))
+		}
+	}
+
+	if resp.RefreshToken == consts.EMPTY_STRING {
+		problems = append(problems, "refresh token missing")
+	} else if problem := tokenProblem(resp.RefreshToken); problem != consts.EMPTY_STRING {
+		problems = append(problems, "refresh token "+problem)
+	}
+
+	if resp.ExpiresIn < 0 {
+		problems = append(problems, fmt.Sprintf("expiresIn is negative (%d)", resp.ExpiresIn))
+	}
+	if resp.RefreshExpiresIn < 0 {
+		problems = append(problems, fmt.Sprintf("refreshExpiresIn is negative (%d)", resp.RefreshExpiresIn))
+	}
+	if resp.ExpiresAt != 0 && !time.Unix(resp.ExpiresAt, 0).After(now) {
+		problems = append(problems, "access token already expired")
+	}
+	if resp.RefreshExpiresAt != 0 && !time.Unix(resp.RefreshExpiresAt, 0).After(now) {
+		problems = append(problems, "refresh token already expired")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return types.NewCodedError(types.ErrCodeLoginFailed,
+		errors.New("the login response is unusable: "+strings.Join(problems, ", ")))
+}
+
+// tokenProblem describes why a token does not look like one, or returns an empty string.
+func tokenProblem(token string) string {
+	if len(token) < minTokenLength {
+		return fmt.Sprintf("is too short (%d characters)", len(token))
+	}
+
+	for _, r := range token {
+		if r <= ' ' || r > '~' {
+			return "contains whitespace or non-printable characters"
+		}
+	}
+
+	return consts.EMPTY_STRING
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestValidateLoginResponse(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	jwtToken := "eyJhbGciOiJub25lIn0.eyJ1aWQiOiIxMjMifQ.signature"
+	opaque := "AAB_0123456789abcdef"
+
+	tests := []struct {
+		name     string
+		resp     types.LoginResponse
+		problems []string
+	}{
+		{name: "JWT access token", resp: types.LoginResponse{AccessToken: jwtToken, RefreshToken: opaque, ExpiresIn: 7776000}},
+		{name: "Opaque access token", resp: types.LoginResponse{AccessToken: opaque, RefreshToken: opaque}},
+		{name: "Empty response", resp: types.LoginResponse{}, problems: []string{"access token missing", "refresh token missing"}},
+		{name: "Refresh token missing", resp: types.LoginResponse{AccessToken: jwtToken}, problems: []string{"refresh token missing"}},
+		{name: "Short token", resp: types.LoginResponse{AccessToken: "abc", RefreshToken: opaque}, problems: []string{"access token is too short"}},
+		{name: "Whitespace in token", resp: types.LoginResponse{AccessToken: opaque, RefreshToken: "refresh token with spaces"}, problems: []string{"refresh token contains whitespace"}},
+		{name: "Broken JWT", resp: types.LoginResponse{AccessToken: "not-base64!.payload.signature", RefreshToken: opaque}, problems: []string{"access token is not a valid JWT"}},
+		{name: "Negative expiry", resp: types.LoginResponse{AccessToken: opaque, RefreshToken: opaque, ExpiresIn: -1, RefreshExpiresIn: -5}, problems: []string{"expiresIn is negative", "refreshExpiresIn is negative"}},
+		{name: "Already expired", resp: types.LoginResponse{AccessToken: opaque, RefreshToken: opaque, ExpiresAt: now.Unix()}, problems: []string{"access token already expired"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLoginResponse(tt.resp, now)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("validateLoginResponse() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("validateLoginResponse() expected an error")
+			}
+			if types.ErrorCode(err) != types.ErrCodeLoginFailed {
+				t.Errorf("ErrorCode() = %v, expected %v", types.ErrorCode(err), types.ErrCodeLoginFailed)
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("validateLoginResponse() = %v, expected it to mention %q", err, problem)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package childprocess
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "env")
+
+	tests := []struct {
+		name     string
+		script   string
+		expected int
+	}{
+		{name: "Success", script: `printf '%s' "$BAMBU_TOKEN" > ` + outPath, expected: 0},
+		{name: "Exit code", script: "exit 7", expected: 7},
+		{name: "Killed by signal", script: "kill -TERM $$", expected: 128 + 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Run("sh", []string{"-c", tt.script}, []types.EnvVar{{Name: "BAMBU_TOKEN", Value: "secret"}})
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if code != tt.expected {
+				t.Errorf("Run() = %d, expected %d", code, tt.expected)
+			}
+		})
+	}
+
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("child saw BAMBU_TOKEN=%q, expected %q", got, "secret")
+	}
+}
+
+func TestRunMissingCommand(t *testing.T) {
+	if _, err := Run("this-command-does-not-exist", nil, nil); err == nil {
+		t.Error("Run() expected an error for a missing command")
+	}
+}
@@ -2,6 +2,7 @@ package consts
 
 import (
 	"errors"
+	"net/url"
 	"strings"
 
 	"github.com/ondrovic/bambulab-authenticator/internal/utils"
@@ -10,13 +11,46 @@ import (
 type URL string
 
 const (
-	EmailCodeURL URL = "https://api.bambulab.com/v1/user-service/user/sendemail/code"
-	LoginURL     URL = "https://api.bambulab.com/v1/user-service/user/login"
-	ProfileURL   URL = "https://api.bambulab.com/v1/user-service/my/profile"
-	RefererURL   URL = "https://bambulab.com"
-	TwoFactorURL URL = "https://bambulab.com/api/sign-in/tfa"
+	BindURL         URL = "https://api.bambulab.com/v1/iot-service/api/user/bind"
+	EmailCodeURL    URL = "https://api.bambulab.com/v1/user-service/user/sendemail/code"
+	LoginURL        URL = "https://api.bambulab.com/v1/user-service/user/login"
+	ProfileURL      URL = "https://api.bambulab.com/v1/user-service/my/profile"
+	RefererURL      URL = "https://bambulab.com"
+	RefreshTokenURL URL = "https://api.bambulab.com/v1/user-service/user/refreshtoken"
+	SMSCodeURL      URL = "https://api.bambulab.com/v1/user-service/user/sendsmscode"
+	TwoFactorURL    URL = "https://bambulab.com/api/sign-in/tfa"
 )
 
+const (
+	// MQTTHost is the cloud MQTT broker for accounts outside China
+	MQTTHost = "us.mqtt.bambulab.com"
+	// MQTTChinaHost is the cloud MQTT broker for China region accounts
+	MQTTChinaHost = "cn.mqtt.bambulab.com"
+	// MQTTPort is the TLS port of the cloud MQTT brokers
+	MQTTPort = "8883"
+)
+
+// MQTTBroker returns the cloud MQTT broker host for the region
+func MQTTBroker(region string) string {
+	if strings.ToLower(region) == "china" {
+		return MQTTChinaHost
+	}
+
+	return MQTTHost
+}
+
+// ChinaPathPrefix is the path prefix China region endpoints are served under when a base URL override is set
+const ChinaPathPrefix = "/cn"
+
+// baseURL replaces the scheme and host of every endpoint when set, used to target a mock server
+var baseURL string
+
+// SetBaseURL redirects every endpoint to the given base URL (e.g. http://127.0.0.1:8080).
+// China region endpoints are served under ChinaPathPrefix. An empty string restores the real endpoints.
+func SetBaseURL(base string) {
+	baseURL = strings.TrimRight(base, "/")
+}
+
 func RegionalURL(url URL, region string) (URL, error) {
 
 	if utils.IsEmpty(region) {
@@ -25,6 +59,14 @@ func RegionalURL(url URL, region string) (URL, error) {
 
 	region = strings.ToLower(region)
 
+	if !utils.IsEmpty(baseURL) {
+		path := url.Path()
+		if region == "china" {
+			path = ChinaPathPrefix + path
+		}
+		return URL(baseURL + path), nil
+	}
+
 	if region == "china" {
 		regionalUrl := strings.Replace(string(url), ".com", ".cn", -1)
 		return URL(regionalUrl), nil
@@ -32,3 +74,13 @@ func RegionalURL(url URL, region string) (URL, error) {
 
 	return url, nil
 }
+
+// Path returns the path component of the URL
+func (u URL) Path() string {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Path
+}
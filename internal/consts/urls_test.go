@@ -48,3 +48,40 @@ func TestRegionalURL(t *testing.T) {
 		})
 	}
 }
+
+func TestRegionalURLWithBaseURL(t *testing.T) {
+	SetBaseURL("http://127.0.0.1:8080/")
+	defer SetBaseURL("")
+
+	tests := []struct {
+		name     string
+		url      URL
+		region   string
+		expected URL
+	}{
+		{
+			name:     "Global region",
+			url:      LoginURL,
+			region:   "us",
+			expected: "http://127.0.0.1:8080/v1/user-service/user/login",
+		},
+		{
+			name:     "China region",
+			url:      TwoFactorURL,
+			region:   "China",
+			expected: "http://127.0.0.1:8080/cn/api/sign-in/tfa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RegionalURL(tt.url, tt.region)
+			if err != nil {
+				t.Fatalf("RegionalURL() unexpected error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("RegionalURL() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
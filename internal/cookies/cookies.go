@@ -0,0 +1,141 @@
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in Netscape files; such lines are not comments.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Parse reads browser cookies exported either as a Netscape cookies.txt file or as JSON, the format
+// being detected from the content. JSON may be an array of cookies (Cookie-Editor, EditThisCookie)
+// or an object with a "cookies" array (Playwright storage state).
+func Parse(data []byte) ([]*http.Cookie, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return parseJSON(trimmed)
+	}
+
+	return parseNetscape(data)
+}
+
+// FilterDomain keeps the cookies set for the domain or one of its subdomains.
+func FilterDomain(cookies []*http.Cookie, domain string) []*http.Cookie {
+	var filtered []*http.Cookie
+	for _, cookie := range cookies {
+		host := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			filtered = append(filtered, cookie)
+		}
+	}
+
+	return filtered
+}
+
+func parseNetscape(data []byte) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookie on line %d: expected 7 tab separated fields, got %d", lineNumber, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie expiry on line %d: %v", lineNumber, err)
+		}
+
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %v", err)
+	}
+
+	return cookies, nil
+}
+
+// jsonCookie covers the fields used by the common browser extension and Playwright exports.
+type jsonCookie struct {
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Secure         bool     `json:"secure"`
+	HTTPOnly       bool     `json:"httpOnly"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"`
+}
+
+func parseJSON(data []byte) ([]*http.Cookie, error) {
+	var exported []jsonCookie
+	if data[0] == '{' {
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse cookies: %v", err)
+		}
+		exported = state.Cookies
+	} else if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("failed to parse cookies: %v", err)
+	}
+
+	cookies := make([]*http.Cookie, 0, len(exported))
+	for _, c := range exported {
+		cookie := &http.Cookie{
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Name:     c.Name,
+			Value:    c.Value,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+
+		expiry := c.ExpirationDate
+		if expiry == nil {
+			expiry = c.Expires
+		}
+		// Session cookies have no expiry, or -1 in Playwright exports
+		if expiry != nil && *expiry > 0 {
+			seconds, fraction := math.Modf(*expiry)
+			cookie.Expires = time.Unix(int64(seconds), int64(fraction*1e9))
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,84 @@
+package cookies
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		expected  []*http.Cookie
+		expectErr bool
+	}{
+		{
+			name: "Netscape",
+			data: "# Netscape HTTP Cookie File\n\n" +
+				"#HttpOnly_.bambulab.com\tTRUE\t/\tTRUE\t1800000000\ttoken\taccess\n" +
+				".bambulab.com\tTRUE\t/\tFALSE\t0\texpiresIn\t7776000\n",
+			expected: []*http.Cookie{
+				{Domain: ".bambulab.com", Path: "/", Secure: true, HttpOnly: true, Name: "token", Value: "access", Expires: time.Unix(1800000000, 0)},
+				{Domain: ".bambulab.com", Path: "/", Name: "expiresIn", Value: "7776000"},
+			},
+		},
+		{
+			name:      "Netscape with missing fields",
+			data:      ".bambulab.com\tTRUE\t/\ttoken\taccess\n",
+			expectErr: true,
+		},
+		{
+			name: "JSON array",
+			data: `[{"domain":".bambulab.com","path":"/","name":"refreshToken","value":"refresh","httpOnly":true,"expirationDate":1800000000.5}]`,
+			expected: []*http.Cookie{
+				{Domain: ".bambulab.com", Path: "/", HttpOnly: true, Name: "refreshToken", Value: "refresh", Expires: time.Unix(1800000000, 5e8)},
+			},
+		},
+		{
+			name: "Playwright storage state",
+			data: `{"cookies":[{"domain":"bambulab.com","path":"/","name":"token","value":"access","expires":-1}],"origins":[]}`,
+			expected: []*http.Cookie{
+				{Domain: "bambulab.com", Path: "/", Name: "token", Value: "access"},
+			},
+		},
+		{
+			name:      "Invalid JSON",
+			data:      `[{"name":`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies, err := Parse([]byte(tt.data))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cookies)
+		})
+	}
+}
+
+func TestFilterDomain(t *testing.T) {
+	cookies := []*http.Cookie{
+		{Domain: ".bambulab.com", Name: "a"},
+		{Domain: "api.bambulab.com", Name: "b"},
+		{Domain: "bambulab.com", Name: "c"},
+		{Domain: "notbambulab.com", Name: "d"},
+		{Domain: ".bambulab.cn", Name: "e"},
+	}
+
+	filtered := FilterDomain(cookies, "bambulab.com")
+
+	names := []string{}
+	for _, cookie := range filtered {
+		names = append(names, cookie.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
@@ -0,0 +1,162 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// Helper actions of the git credential helper protocol.
+const (
+	ActionGet   = "get"
+	ActionStore = "store"
+	ActionErase = "erase"
+)
+
+// Credential is the set of attributes exchanged with a credential helper.
+type Credential struct {
+	Protocol string
+	Host     string
+	Username string
+	Password string
+}
+
+// RunCommand executes a secret command (e.g. "pass show bambu") and returns the first line
+// of its stdout. Stderr and stdin are passed through so the helper can ask for a passphrase.
+func RunCommand(command string) (string, error) {
+	cmd := utils.ShellCommand(command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret command failed: %v", err)
+	}
+
+	secret, _, _ := strings.Cut(string(out), "\n")
+	secret = strings.TrimRight(secret, "\r")
+	if secret == "" {
+		return "", fmt.Errorf("secret command returned nothing")
+	}
+
+	return secret, nil
+}
+
+// Format serializes a credential as key=value lines terminated by a blank line. Like git, it refuses
+// values with a line break or NUL, which would let a value inject attributes of its own.
+func (c Credential) Format() (string, error) {
+	var b strings.Builder
+	for _, attr := range [][2]string{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if strings.ContainsAny(attr[1], "\r\n\x00") {
+			return "", fmt.Errorf("credential %s contains a line break or NUL", attr[0])
+		}
+		if attr[1] != "" {
+			fmt.Fprintf(&b, "%s=%s\n", attr[0], attr[1])
+		}
+	}
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+// Parse reads key=value lines up to a blank line or the end of input. Unknown keys are ignored.
+func Parse(data []byte) (Credential, error) {
+	var credential Credential
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Credential{}, fmt.Errorf("invalid credential line: %q", line)
+		}
+
+		switch key {
+		case "protocol":
+			credential.Protocol = value
+		case "host":
+			credential.Host = value
+		case "username":
+			credential.Username = value
+		case "password":
+			credential.Password = value
+		}
+	}
+
+	return credential, scanner.Err()
+}
+
+// Helper runs a git-style credential helper. The action is appended to the helper command line,
+// so "git credential-osxkeychain" or "git-credential-libsecret" work unchanged.
+type Helper struct {
+	Command string
+}
+
+// Get asks the helper for the password matching the credential's protocol, host and username.
+func (h Helper) Get(credential Credential) (Credential, error) {
+	out, err := h.run(ActionGet, credential)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	found, err := Parse(out)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	// Keep the attributes the helper did not echo back
+	if found.Protocol == "" {
+		found.Protocol = credential.Protocol
+	}
+	if found.Host == "" {
+		found.Host = credential.Host
+	}
+	if found.Username == "" {
+		found.Username = credential.Username
+	}
+
+	return found, nil
+}
+
+// Store tells the helper the credential worked and may be saved.
+func (h Helper) Store(credential Credential) error {
+	_, err := h.run(ActionStore, credential)
+	return err
+}
+
+// Erase tells the helper the credential was rejected and should be forgotten.
+func (h Helper) Erase(credential Credential) error {
+	_, err := h.run(ActionErase, credential)
+	return err
+}
+
+func (h Helper) run(action string, credential Credential) ([]byte, error) {
+	input, err := credential.Format()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s refused: %v", action, err)
+	}
+
+	cmd := utils.ShellCommand(h.Command + " " + action)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %v", action, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper scripts use sh")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	skipOnWindows(t)
+
+	tests := []struct {
+		name      string
+		command   string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "First line only",
+			command:  `printf 'secret\nsecond line\n'`,
+			expected: "secret",
+		},
+		{
+			name:      "Failing command",
+			command:   "exit 3",
+			expectErr: true,
+		},
+		{
+			name:      "Empty output",
+			command:   "true",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RunCommand(tt.command)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestFormatAndParse(t *testing.T) {
+	credential := Credential{
+		Protocol: "https",
+		Host:     "bambulab.com",
+		Username: "user@example.com",
+		Password: "p=ss",
+	}
+
+	formatted, err := credential.Format()
+	require.NoError(t, err)
+	assert.Equal(t, "protocol=https\nhost=bambulab.com\nusername=user@example.com\npassword=p=ss\n\n", formatted)
+
+	parsed, err := Parse([]byte(formatted + "ignored=after blank line\n"))
+	require.NoError(t, err)
+	assert.Equal(t, credential, parsed)
+
+	_, err = Parse([]byte("not a pair\n"))
+	assert.Error(t, err)
+}
+
+func TestFormatRejectsLineBreaks(t *testing.T) {
+	tests := []struct {
+		name       string
+		credential Credential
+	}{
+		{name: "Newline in password", credential: Credential{Username: "user@example.com", Password: "secret\nhost=evil.example.com"}},
+		{name: "Carriage return in username", credential: Credential{Username: "user@example.com\rpassword=x"}},
+		{name: "NUL in host", credential: Credential{Host: "bambulab.com\x00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.credential.Format()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHelperNotRunWithLineBreaks(t *testing.T) {
+	skipOnWindows(t)
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	helper := Helper{Command: "touch " + marker + " #"}
+
+	err := helper.Store(Credential{Username: "user@example.com", Password: "secret\nusername=other"})
+	require.Error(t, err)
+	assert.NoFileExists(t, marker, "The helper must not see injected attributes")
+}
+
+func TestHelper(t *testing.T) {
+	skipOnWindows(t)
+
+	// A helper that records every action and its input, and answers get with a password
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "helper.sh")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "action=$1" >> "`+logPath+`"
+cat >> "`+logPath+`"
+if [ "$1" = "get" ]; then
+  echo "password=from-helper"
+fi
+`), 0700))
+
+	helper := Helper{Command: script}
+	request := Credential{Protocol: "https", Host: "bambulab.com", Username: "user@example.com"}
+
+	got, err := helper.Get(request)
+	require.NoError(t, err)
+	assert.Equal(t, "from-helper", got.Password)
+	assert.Equal(t, request.Username, got.Username, "Username should be kept when not echoed")
+
+	require.NoError(t, helper.Store(got))
+	require.NoError(t, helper.Erase(request))
+
+	log, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, "action=get\nprotocol=https\nhost=bambulab.com\nusername=user@example.com\n\n"+
+		"action=store\nprotocol=https\nhost=bambulab.com\nusername=user@example.com\npassword=from-helper\n\n"+
+		"action=erase\nprotocol=https\nhost=bambulab.com\nusername=user@example.com\n\n", string(log))
+}
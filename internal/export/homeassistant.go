@@ -0,0 +1,167 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSecretsPrefix starts every Home Assistant secret key written by the export.
+const DefaultSecretsPrefix = "bambu"
+
+// secretsHeader introduces the entries appended to a secrets file.
+const secretsHeader = "# Bambu Lab, updated by bambulab-authenticator"
+
+// Entry is a key and value of a Home Assistant secrets file.
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// HomeAssistantSecrets returns the secrets for the session followed by the serial and LAN access code
+// of every printer, keyed <prefix>_<printer name>_serial and <prefix>_<printer name>_access_code.
+func HomeAssistantSecrets(prefix string, tokens *types.LoginResponse, uid string, region string, devices []types.Device) []Entry {
+	if prefix == "" {
+		prefix = DefaultSecretsPrefix
+	}
+
+	entries := []Entry{
+		{Key: prefix + "_token", Value: tokens.AccessToken},
+		{Key: prefix + "_refresh_token", Value: tokens.RefreshToken},
+	}
+	if uid != "" {
+		entries = append(entries, Entry{Key: prefix + "_uid", Value: uid})
+	}
+	entries = append(entries, Entry{Key: prefix + "_region", Value: region})
+
+	used := map[string]bool{}
+	for _, device := range devices {
+		name := slug(device.Name)
+		if name == "" || used[name] {
+			// Fall back to the serial for unnamed printers and duplicate names
+			name = slug(device.Serial)
+		}
+		used[name] = true
+
+		entries = append(entries,
+			Entry{Key: prefix + "_" + name + "_serial", Value: device.Serial},
+			Entry{Key: prefix + "_" + name + "_access_code", Value: device.AccessCode},
+		)
+	}
+
+	return entries
+}
+
+// slug lowercases the name and replaces everything but letters and digits with underscores.
+func slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	return strings.Trim(b.String(), "_")
+}
+
+// FormatSecrets renders the entries as a secrets.yaml snippet.
+func FormatSecrets(entries []Entry) ([]byte, error) {
+	return MergeSecrets(nil, entries)
+}
+
+// MergeSecrets updates the entries in an existing secrets.yaml, appending the ones it lacks. The file
+// is edited line by line so other keys, comments and blank lines are kept as they are.
+func MergeSecrets(existing []byte, entries []Entry) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %v", err)
+	}
+
+	var pairs []*yaml.Node
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("secrets file is not a mapping of keys to values")
+		}
+		pairs = root.Content
+	}
+
+	values := map[string]string{}
+	for _, entry := range entries {
+		values[entry.Key] = entry.Value
+	}
+
+	lines := strings.Split(string(existing), "\n")
+	found := map[string]bool{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		newValue, ok := values[key.Value]
+		if !ok {
+			continue
+		}
+		found[key.Value] = true
+
+		if value.Kind != yaml.ScalarNode || value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || value.Line != key.Line {
+			return nil, fmt.Errorf("cannot update the multi-line value of %v, remove it first", key.Value)
+		}
+
+		line := []rune(lines[value.Line-1])
+		updated := string(line[:value.Column-1]) + strconv.Quote(newValue)
+		if value.LineComment != "" {
+			updated += " " + value.LineComment
+		}
+		lines[value.Line-1] = updated
+	}
+
+	merged := strings.Join(lines, "\n")
+
+	var missing []Entry
+	for _, entry := range entries {
+		if !found[entry.Key] {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) > 0 {
+		var b strings.Builder
+		b.WriteString(merged)
+		if merged != "" && !strings.HasSuffix(merged, "\n") {
+			b.WriteString("\n")
+		}
+		if strings.TrimSpace(merged) != "" {
+			b.WriteString("\n")
+		}
+		b.WriteString(secretsHeader + "\n")
+		for _, entry := range missing {
+			fmt.Fprintf(&b, "%s: %s\n", entry.Key, strconv.Quote(entry.Value))
+		}
+		merged = b.String()
+	}
+
+	if err := verifySecrets([]byte(merged), values); err != nil {
+		return nil, err
+	}
+
+	return []byte(merged), nil
+}
+
+// verifySecrets parses the merged file and checks every entry reads back with its new value.
+func verifySecrets(merged []byte, values map[string]string) error {
+	var parsed map[string]any
+	if err := yaml.NewDecoder(bytes.NewReader(merged)).Decode(&parsed); err != nil {
+		return fmt.Errorf("merged secrets file is invalid: %v", err)
+	}
+
+	for key, value := range values {
+		if got, ok := parsed[key].(string); !ok || got != value {
+			return fmt.Errorf("failed to update %v in the secrets file", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomeAssistantSecrets(t *testing.T) {
+	tokens := &types.LoginResponse{AccessToken: "access", RefreshToken: "refresh"}
+	devices := []types.Device{
+		{Serial: "01P00A1", Name: "Garage P1S", AccessCode: "11111111"},
+		{Serial: "01P00A2", Name: "Garage P1S", AccessCode: "22222222"},
+	}
+
+	entries := HomeAssistantSecrets("", tokens, "42", "us", devices)
+
+	assert.Equal(t, []Entry{
+		{Key: "bambu_token", Value: "access"},
+		{Key: "bambu_refresh_token", Value: "refresh"},
+		{Key: "bambu_uid", Value: "42"},
+		{Key: "bambu_region", Value: "us"},
+		{Key: "bambu_garage_p1s_serial", Value: "01P00A1"},
+		{Key: "bambu_garage_p1s_access_code", Value: "11111111"},
+		{Key: "bambu_01p00a2_serial", Value: "01P00A2"},
+		{Key: "bambu_01p00a2_access_code", Value: "22222222"},
+	}, entries)
+}
+
+func TestMergeSecrets(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		entries   []Entry
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "Empty file",
+			existing: "",
+			entries:  []Entry{{Key: "bambu_token", Value: "new"}},
+			expected: secretsHeader + "\nbambu_token: \"new\"\n",
+		},
+		{
+			name: "Updates in place and keeps comments",
+			existing: `# Home Assistant secrets
+wifi_password: hunter2
+
+bambu_token: old # rotated by cron
+bambu_region: 'us'
+`,
+			entries: []Entry{{Key: "bambu_token", Value: "new"}, {Key: "bambu_region", Value: "us"}},
+			expected: `# Home Assistant secrets
+wifi_password: hunter2
+
+bambu_token: "new" # rotated by cron
+bambu_region: "us"
+`,
+		},
+		{
+			name:     "Appends missing keys",
+			existing: "wifi_password: hunter2",
+			entries:  []Entry{{Key: "bambu_access_code", Value: "01234567"}},
+			expected: "wifi_password: hunter2\n\n" + secretsHeader + "\nbambu_access_code: \"01234567\"\n",
+		},
+		{
+			name:      "Multi-line value",
+			existing:  "bambu_token: |\n  old\n",
+			entries:   []Entry{{Key: "bambu_token", Value: "new"}},
+			expectErr: true,
+		},
+		{
+			name:      "Not a mapping",
+			existing:  "- item\n",
+			entries:   []Entry{{Key: "bambu_token", Value: "new"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeSecrets([]byte(tt.existing), tt.entries)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(merged))
+		})
+	}
+}
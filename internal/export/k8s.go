@@ -0,0 +1,183 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Sealed Secrets scopes, see https://github.com/bitnami-labs/sealed-secrets#scopes.
+const (
+	ScopeStrict        = "strict"
+	ScopeNamespaceWide = "namespace-wide"
+	ScopeClusterWide   = "cluster-wide"
+)
+
+// DefaultSecretName is used when no Secret name is given.
+const DefaultSecretName = "bambulab-auth"
+
+// KustomizationFile is the file name kustomize looks for in a directory.
+const KustomizationFile = "kustomization.yaml"
+
+// K8sSecretOptions describes the Secret to generate.
+type K8sSecretOptions struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	// SealedSecretScope adds the annotation kubeseal reads to pick the scope. Empty leaves it out.
+	SealedSecretScope string
+}
+
+type k8sMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type kustomization struct {
+	APIVersion      string               `yaml:"apiVersion"`
+	Kind            string               `yaml:"kind"`
+	Namespace       string               `yaml:"namespace,omitempty"`
+	SecretGenerator []kustomizeGenerator `yaml:"secretGenerator"`
+}
+
+type kustomizeGenerator struct {
+	Name    string                  `yaml:"name"`
+	Type    string                  `yaml:"type"`
+	Envs    []string                `yaml:"envs"`
+	Options kustomizeGeneratorFlags `yaml:"options"`
+}
+
+type kustomizeGeneratorFlags struct {
+	Labels                map[string]string `yaml:"labels,omitempty"`
+	Annotations           map[string]string `yaml:"annotations,omitempty"`
+	DisableNameSuffixHash bool              `yaml:"disableNameSuffixHash"`
+}
+
+// Validate checks the Secret name, namespace and sealed secret scope, filling in the default name.
+func (o *K8sSecretOptions) Validate() error {
+	if o.Name == "" {
+		o.Name = DefaultSecretName
+	}
+
+	switch o.SealedSecretScope {
+	case "", ScopeNamespaceWide, ScopeClusterWide:
+	case ScopeStrict:
+		// A strict secret is sealed for its exact name and namespace
+		if o.Namespace == "" {
+			return fmt.Errorf("a namespace is required for the %v sealed secret scope", ScopeStrict)
+		}
+	default:
+		return fmt.Errorf("unknown sealed secret scope: %v", o.SealedSecretScope)
+	}
+
+	return nil
+}
+
+// annotations returns the kubeseal scope annotation, if any.
+func (o K8sSecretOptions) annotations() map[string]string {
+	switch o.SealedSecretScope {
+	case ScopeNamespaceWide, ScopeClusterWide:
+		return map[string]string{"sealedsecrets.bitnami.com/" + o.SealedSecretScope: "true"}
+	default:
+		return nil
+	}
+}
+
+// K8sSecret renders the variables as a v1 Secret manifest with base64 encoded data. The output can be
+// applied directly or piped to kubeseal.
+func K8sSecret(env []types.EnvVar, opts K8sSecretOptions) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sMetadata{
+			Name:        opts.Name,
+			Namespace:   opts.Namespace,
+			Labels:      opts.Labels,
+			Annotations: opts.annotations(),
+		},
+		Type: "Opaque",
+		Data: map[string]string{},
+	}
+
+	for _, variable := range env {
+		secret.Data[variable.Name] = base64.StdEncoding.EncodeToString([]byte(variable.Value))
+	}
+
+	return marshalYAML(secret)
+}
+
+// Kustomize returns the files of a kustomize directory generating the Secret: a kustomization.yaml
+// with a secretGenerator and the env file it reads, keyed by file name.
+func Kustomize(env []types.EnvVar, opts K8sSecretOptions) (map[string][]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	envFile := opts.Name + ".env"
+
+	var values strings.Builder
+	for _, variable := range env {
+		if strings.ContainsAny(variable.Value, "\r\n") {
+			return nil, fmt.Errorf("value of %v cannot be written to an env file", variable.Name)
+		}
+		fmt.Fprintf(&values, "%s=%s\n", variable.Name, variable.Value)
+	}
+
+	manifest, err := marshalYAML(kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Namespace:  opts.Namespace,
+		SecretGenerator: []kustomizeGenerator{{
+			Name: opts.Name,
+			Type: "Opaque",
+			Envs: []string{envFile},
+			Options: kustomizeGeneratorFlags{
+				Labels:      opts.Labels,
+				Annotations: opts.annotations(),
+				// Keep the name stable so workloads can reference it
+				DisableNameSuffixHash: true,
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		KustomizationFile: manifest,
+		envFile:           []byte(values.String()),
+	}, nil
+}
+
+func marshalYAML(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to marshal yaml: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal yaml: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var testEnv = []types.EnvVar{
+	{Name: "BAMBU_TOKEN", Value: "access"},
+	{Name: "BAMBU_UID", Value: "1234567890"},
+}
+
+func TestK8sSecret(t *testing.T) {
+	manifest, err := K8sSecret(testEnv, K8sSecretOptions{
+		Namespace:         "printers",
+		Labels:            map[string]string{"app": "monitor"},
+		SealedSecretScope: ScopeClusterWide,
+	})
+	require.NoError(t, err)
+
+	var secret k8sSecret
+	require.NoError(t, yaml.Unmarshal(manifest, &secret))
+	assert.Equal(t, "v1", secret.APIVersion)
+	assert.Equal(t, "Secret", secret.Kind)
+	assert.Equal(t, DefaultSecretName, secret.Metadata.Name)
+	assert.Equal(t, "printers", secret.Metadata.Namespace)
+	assert.Equal(t, "monitor", secret.Metadata.Labels["app"])
+	assert.Equal(t, "true", secret.Metadata.Annotations["sealedsecrets.bitnami.com/cluster-wide"])
+	assert.Equal(t, "YWNjZXNz", secret.Data["BAMBU_TOKEN"])
+	assert.Equal(t, "MTIzNDU2Nzg5MA==", secret.Data["BAMBU_UID"])
+}
+
+func TestK8sSecretOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      K8sSecretOptions
+		expectErr bool
+	}{
+		{name: "Defaults", opts: K8sSecretOptions{}},
+		{name: "Strict with namespace", opts: K8sSecretOptions{Namespace: "printers", SealedSecretScope: ScopeStrict}},
+		{name: "Strict without namespace", opts: K8sSecretOptions{SealedSecretScope: ScopeStrict}, expectErr: true},
+		{name: "Unknown scope", opts: K8sSecretOptions{SealedSecretScope: "global"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, tt.opts.Name)
+		})
+	}
+}
+
+func TestKustomize(t *testing.T) {
+	files, err := Kustomize(testEnv, K8sSecretOptions{Name: "bambu", Namespace: "printers"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "BAMBU_TOKEN=access\nBAMBU_UID=1234567890\n", string(files["bambu.env"]))
+
+	var manifest kustomization
+	require.NoError(t, yaml.Unmarshal(files[KustomizationFile], &manifest))
+	assert.Equal(t, "printers", manifest.Namespace)
+	require.Len(t, manifest.SecretGenerator, 1)
+	assert.Equal(t, "bambu", manifest.SecretGenerator[0].Name)
+	assert.Equal(t, []string{"bambu.env"}, manifest.SecretGenerator[0].Envs)
+	assert.True(t, manifest.SecretGenerator[0].Options.DisableNameSuffixHash)
+
+	_, err = Kustomize([]types.EnvVar{{Name: "BAMBU_TOKEN", Value: "a\nb"}}, K8sSecretOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// DirName is the directory inside the output path that holds the previous auth files.
+const DirName = "history"
+
+// DefaultSize is how many previous auth files the command line keeps by default.
+const DefaultSize = 5
+
+// timeLayout names backups so they sort by age; the time is when the auth file was replaced.
+const timeLayout = "20060102T150405.000000000Z"
+
+// Validity of a saved token set.
+const (
+	StatusValid       = "valid"
+	StatusRefreshable = "refreshable"
+	StatusExpired     = "expired"
+)
+
+// Entry is one previous auth file. Index 1 is the most recent one.
+type Entry struct {
+	Index   int                  `json:"index"`
+	Path    string               `json:"path"`
+	SavedAt time.Time            `json:"savedAt"`
+	Tokens  *types.LoginResponse `json:"-"`
+	// Error explains why the file could not be read, Tokens is nil then.
+	Error string `json:"error,omitempty"`
+}
+
+// Status reports whether the entry's access token is still valid, can be refreshed, or is expired.
+func (e Entry) Status(now time.Time) string {
+	switch {
+	case e.Tokens == nil:
+		return StatusExpired
+	case e.Tokens.AccessToken != "" && !e.Tokens.ExpiresWithin(now, 0):
+		return StatusValid
+	case e.Tokens.CanRefresh(now):
+		return StatusRefreshable
+	default:
+		return StatusExpired
+	}
+}
+
+// Dir returns the history directory of the given output path.
+func Dir(path string) string {
+	return filepath.Join(path, DirName)
+}
+
+// Backup copies the current auth file of path, if any, into the history and keeps the newest keep
+// backups. A keep of zero or less disables the history.
+func Backup(path string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(utils.AuthFilePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read auth file for history: %v", err)
+	}
+
+	if err := os.MkdirAll(Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	name := "auth-" + time.Now().UTC().Format(timeLayout) + ".json"
+	if err := os.WriteFile(filepath.Join(Dir(path), name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write history entry: %v", err)
+	}
+
+	return prune(path, keep)
+}
+
+// prune removes all but the newest keep backups.
+func prune(path string, keep int) error {
+	names, err := backupNames(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names[min(keep, len(names)):] {
+		if err := os.Remove(filepath.Join(Dir(path), name)); err != nil {
+			return fmt.Errorf("failed to remove old history entry: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// backupNames returns the backup file names, newest first.
+func backupNames(path string) ([]string, error) {
+	files, err := os.ReadDir(Dir(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %v", err)
+	}
+
+	var names []string
+	for _, file := range files {
+		if _, ok := backupTime(file.Name()); ok && !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	return names, nil
+}
+
+func backupTime(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, "auth-")
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, ".json")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	savedAt, err := time.Parse(timeLayout, stamp)
+	return savedAt, err == nil
+}
+
+// List returns the backups of path, newest first.
+func List(path string) ([]Entry, error) {
+	names, err := backupNames(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(names))
+	for i, name := range names {
+		savedAt, _ := backupTime(name)
+		entry := Entry{Index: i + 1, Path: filepath.Join(Dir(path), name), SavedAt: savedAt}
+
+		data, err := os.ReadFile(entry.Path)
+		if err == nil {
+			var tokens types.LoginResponse
+			if err = json.Unmarshal(data, &tokens); err == nil {
+				entry.Tokens = &tokens
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Restore makes backup n (1 is the newest) the current auth file. The entry moves out of the history
+// and the replaced auth file takes its place, so a restore can itself be rolled back and the prune
+// that follows never drops an entry the restore did not add.
+func Restore(path string, n int, keep int) (*Entry, error) {
+	entries, err := List(path)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(entries) {
+		return nil, types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("no history entry %d, there are %d", n, len(entries)))
+	}
+
+	entry := entries[n-1]
+	if entry.Tokens == nil {
+		return nil, fmt.Errorf("history entry %d cannot be read: %v", n, entry.Error)
+	}
+
+	data, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history entry: %v", err)
+	}
+
+	// Out of the history before the backup, so a full history does not prune the entry or another one
+	if err := os.Remove(entry.Path); err != nil {
+		return nil, fmt.Errorf("failed to remove history entry: %v", err)
+	}
+
+	if err := Backup(path, max(keep, 1)); err != nil {
+		putBack(entry.Path, data)
+		return nil, err
+	}
+
+	if err := utils.WriteFileAtomic(utils.AuthFilePath(path), data, 0644); err != nil {
+		putBack(entry.Path, data)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// putBack returns an entry taken out by a failed restore to the history.
+func putBack(entryPath string, data []byte) {
+	if err := os.WriteFile(entryPath, data, 0600); err != nil {
+		slog.Warn("failed to put history entry back", "path", entryPath, "error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func saveTokens(t *testing.T, path string, accessToken string) {
+	t.Helper()
+	require.NoError(t, Backup(path, 3))
+	require.NoError(t, utils.SaveLoginResponseToFile(types.LoginResponse{AccessToken: accessToken, RefreshToken: "refresh", ExpiresIn: 3600}, path))
+}
+
+func TestBackupKeepsNewest(t *testing.T) {
+	path := t.TempDir()
+
+	for _, token := range []string{"first", "second", "third", "fourth", "fifth"} {
+		saveTokens(t, path, token)
+	}
+
+	entries, err := List(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	tokens := []string{}
+	for i, entry := range entries {
+		assert.Equal(t, i+1, entry.Index)
+		require.NotNil(t, entry.Tokens)
+		tokens = append(tokens, entry.Tokens.AccessToken)
+	}
+	assert.Equal(t, []string{"fourth", "third", "second"}, tokens, "Entries should be listed newest first")
+
+	info, err := os.Stat(entries[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestBackupDisabled(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, utils.SaveLoginResponseToFile(types.LoginResponse{AccessToken: "first"}, path))
+
+	require.NoError(t, Backup(path, 0))
+
+	entries, err := List(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRestore(t *testing.T) {
+	path := t.TempDir()
+	for _, token := range []string{"first", "second", "third"} {
+		saveTokens(t, path, token)
+	}
+
+	// Entry 2 is "first", the current "third" goes into the history
+	entry, err := Restore(path, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "first", entry.Tokens.AccessToken)
+
+	current, err := utils.LoadLoginResponseFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first", current.AccessToken)
+
+	entries, err := List(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, "third", entries[0].Tokens.AccessToken, "The replaced auth file should be kept")
+
+	_, err = Restore(path, 9, 3)
+	assert.Equal(t, types.ErrCodeInvalidArgument, types.ErrorCode(err))
+}
+
+func TestRestoreWithFullHistory(t *testing.T) {
+	path := t.TempDir()
+	for _, token := range []string{"first", "second", "third", "fourth"} {
+		saveTokens(t, path, token)
+	}
+
+	tokensOf := func() []string {
+		entries, err := List(path)
+		require.NoError(t, err)
+		tokens := []string{}
+		for _, entry := range entries {
+			tokens = append(tokens, entry.Tokens.AccessToken)
+		}
+		return tokens
+	}
+	require.Equal(t, []string{"third", "second", "first"}, tokensOf())
+
+	// The oldest entry is restored, it must not be pruned while the history is full
+	entry, err := Restore(path, 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "first", entry.Tokens.AccessToken)
+	assert.Equal(t, []string{"fourth", "third", "second"}, tokensOf(), "Only the restored entry should leave the history")
+
+	// Restoring again rolls the first restore back without losing either token set
+	entry, err = Restore(path, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth", entry.Tokens.AccessToken)
+	assert.Equal(t, []string{"first", "third", "second"}, tokensOf())
+
+	current, err := utils.LoadLoginResponseFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth", current.AccessToken)
+}
+
+func TestEntryStatus(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour).Unix()
+	future := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		tokens   *types.LoginResponse
+		expected string
+	}{
+		{name: "Unreadable", tokens: nil, expected: StatusExpired},
+		{name: "Valid", tokens: &types.LoginResponse{AccessToken: "a", ExpiresAt: future}, expected: StatusValid},
+		{name: "Refreshable", tokens: &types.LoginResponse{AccessToken: "a", ExpiresAt: past, RefreshToken: "r", RefreshExpiresAt: future}, expected: StatusRefreshable},
+		{name: "Expired", tokens: &types.LoginResponse{AccessToken: "a", ExpiresAt: past, RefreshToken: "r", RefreshExpiresAt: past}, expected: StatusExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Entry{Tokens: tt.tokens}.Status(now))
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in header profile names.
+const (
+	ProfileBrowser     = "browser"
+	ProfileBambuStudio = "bambu-studio"
+	ProfileBambuHandy  = "bambu-handy"
+)
+
+// HeaderProfile is the set of headers sent to make requests look like they come from one Bambu client.
+type HeaderProfile struct {
+	Name string `yaml:"name"`
+	// Common headers are sent with every request.
+	Common map[string]string `yaml:"common"`
+	// Web headers are added for the bambulab.com website endpoints (e.g. the 2FA sign-in).
+	Web map[string]string `yaml:"web"`
+	// API headers are added for the api.bambulab.com endpoints.
+	API map[string]string `yaml:"api"`
+}
+
+// HeaderProfiles lists the built-in profiles by name.
+var HeaderProfiles = map[string]HeaderProfile{
+	ProfileBrowser: {
+		Name: ProfileBrowser,
+		Common: map[string]string{
+			"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:132.0) Gecko/20100101 Firefox/132.0",
+			"Accept":     "*/*",
+			"Connection": "keep-alive",
+			"Referer":    string(consts.RefererURL),
+		},
+	},
+	ProfileBambuStudio: {
+		Name: ProfileBambuStudio,
+		Common: map[string]string{
+			"User-Agent":           "BambuStudio/01.10.01.50",
+			"Accept":               "application/json",
+			"X-BBL-Client-Name":    "BambuStudio",
+			"X-BBL-Client-Type":    "slicer",
+			"X-BBL-Client-Version": "01.10.01.50",
+			"X-BBL-Language":       "en-US",
+			"X-BBL-OS-Type":        "windows",
+		},
+		Web: map[string]string{
+			"Referer": string(consts.RefererURL),
+		},
+	},
+	ProfileBambuHandy: {
+		Name: ProfileBambuHandy,
+		Common: map[string]string{
+			"User-Agent":           "bambu_handy/3.3.0 (Android)",
+			"Accept":               "application/json",
+			"X-BBL-Client-Name":    "BambuHandy",
+			"X-BBL-Client-Type":    "app",
+			"X-BBL-Client-Version": "3.3.0",
+			"X-BBL-Language":       "en-US",
+			"X-BBL-OS-Type":        "android",
+		},
+	},
+}
+
+// profile is the header profile applied to every request.
+var profile = HeaderProfiles[ProfileBrowser]
+
+// SetHeaderProfile selects the headers sent with every following request.
+func SetHeaderProfile(p HeaderProfile) {
+	profile = p
+}
+
+// HeaderProfileNames returns the names of the built-in profiles, sorted.
+func HeaderProfileNames() []string {
+	names := make([]string, 0, len(HeaderProfiles))
+	for name := range HeaderProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseHeaderProfile returns the built-in profile with the given name.
+func ParseHeaderProfile(name string) (HeaderProfile, error) {
+	p, ok := HeaderProfiles[strings.ToLower(name)]
+	if !ok {
+		return HeaderProfile{}, fmt.Errorf("unknown header profile %q, expected one of %v", name, strings.Join(HeaderProfileNames(), ", "))
+	}
+
+	return p, nil
+}
+
+// LoadHeaderProfile reads a custom profile from a YAML (or JSON) file with common, web and api header maps.
+func LoadHeaderProfile(path string) (HeaderProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HeaderProfile{}, fmt.Errorf("failed to read header profile: %v", err)
+	}
+
+	var p HeaderProfile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return HeaderProfile{}, fmt.Errorf("failed to parse header profile: %v", err)
+	}
+	if len(p.Common) == 0 && len(p.Web) == 0 && len(p.API) == 0 {
+		return HeaderProfile{}, fmt.Errorf("header profile %v defines no headers", path)
+	}
+	if p.Name == consts.EMPTY_STRING {
+		p.Name = path
+	}
+
+	return p, nil
+}
+
+// isAPIEndpoint reports whether the request goes to the api.bambulab.com service rather than the
+// website. Mock servers serve both under one host, so the /v1/ path decides there.
+func isAPIEndpoint(req *http.Request) bool {
+	if strings.HasPrefix(req.URL.Hostname(), "api.") {
+		return true
+	}
+
+	return strings.HasPrefix(strings.TrimPrefix(req.URL.Path, consts.ChinaPathPrefix), "/v1/")
+}
+
+// addProfileHeaders sets the headers of the selected profile for the request's endpoint type.
+// Request bodies are JSON unless the profile says otherwise.
+func addProfileHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range profile.Common {
+		req.Header.Set(key, value)
+	}
+
+	endpoint := profile.Web
+	if isAPIEndpoint(req) {
+		endpoint = profile.API
+	}
+	for key, value := range endpoint {
+		req.Header.Set(key, value)
+	}
+}
@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAddProfileHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  string
+		url      string
+		expected http.Header
+	}{
+		{
+			name:    "Browser",
+			profile: ProfileBrowser,
+			url:     "https://api.bambulab.com/v1/user-service/user/login",
+			expected: http.Header{
+				"Content-Type": {"application/json"},
+				"User-Agent":   {"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:132.0) Gecko/20100101 Firefox/132.0"},
+				"Accept":       {"*/*"},
+				"Connection":   {"keep-alive"},
+				"Referer":      {"https://bambulab.com"},
+			},
+		},
+		{
+			name:    "Bambu Studio API endpoint",
+			profile: ProfileBambuStudio,
+			url:     "https://api.bambulab.com/v1/user-service/user/login",
+			expected: http.Header{
+				"Content-Type":         {"application/json"},
+				"User-Agent":           {"BambuStudio/01.10.01.50"},
+				"Accept":               {"application/json"},
+				"X-Bbl-Client-Name":    {"BambuStudio"},
+				"X-Bbl-Client-Type":    {"slicer"},
+				"X-Bbl-Client-Version": {"01.10.01.50"},
+				"X-Bbl-Language":       {"en-US"},
+				"X-Bbl-Os-Type":        {"windows"},
+			},
+		},
+		{
+			name:    "Bambu Studio web endpoint",
+			profile: ProfileBambuStudio,
+			url:     "https://bambulab.com/api/sign-in/tfa",
+			expected: http.Header{
+				"Content-Type":         {"application/json"},
+				"User-Agent":           {"BambuStudio/01.10.01.50"},
+				"Accept":               {"application/json"},
+				"Referer":              {"https://bambulab.com"},
+				"X-Bbl-Client-Name":    {"BambuStudio"},
+				"X-Bbl-Client-Type":    {"slicer"},
+				"X-Bbl-Client-Version": {"01.10.01.50"},
+				"X-Bbl-Language":       {"en-US"},
+				"X-Bbl-Os-Type":        {"windows"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseHeaderProfile(tt.profile)
+			if err != nil {
+				t.Fatalf("ParseHeaderProfile() error = %v", err)
+			}
+			SetHeaderProfile(p)
+			t.Cleanup(func() { SetHeaderProfile(HeaderProfiles[ProfileBrowser]) })
+
+			req, err := http.NewRequest(http.MethodPost, tt.url, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			addProfileHeaders(req)
+
+			if !reflect.DeepEqual(req.Header, tt.expected) {
+				t.Errorf("Headers do not match. Expected: %v, got: %v", tt.expected, req.Header)
+			}
+		})
+	}
+}
+
+func TestIsAPIEndpoint(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{url: "https://api.bambulab.com/v1/user-service/user/login", expected: true},
+		{url: "https://api.bambulab.cn/v1/user-service/user/login", expected: true},
+		{url: "https://bambulab.com/api/sign-in/tfa", expected: false},
+		{url: "http://127.0.0.1:8080/v1/user-service/user/login", expected: true},
+		{url: "http://127.0.0.1:8080/cn/v1/user-service/user/login", expected: true},
+		{url: "http://127.0.0.1:8080/api/sign-in/tfa", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			if got := isAPIEndpoint(req); got != tt.expected {
+				t.Errorf("isAPIEndpoint() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadHeaderProfile(t *testing.T) {
+	dir := t.TempDir()
+
+	custom := filepath.Join(dir, "custom.yaml")
+	content := "name: orca\ncommon:\n  User-Agent: OrcaSlicer/2.2.0\napi:\n  X-BBL-Client-Type: slicer\n"
+	if err := os.WriteFile(custom, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadHeaderProfile(custom)
+	if err != nil {
+		t.Fatalf("LoadHeaderProfile() error = %v", err)
+	}
+	if p.Name != "orca" || p.Common["User-Agent"] != "OrcaSlicer/2.2.0" || p.API["X-BBL-Client-Type"] != "slicer" {
+		t.Errorf("LoadHeaderProfile() = %+v", p)
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, []byte("name: nothing\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHeaderProfile(empty); err == nil {
+		t.Error("LoadHeaderProfile() accepted a profile without headers")
+	}
+
+	if _, err := ParseHeaderProfile("netscape"); err == nil {
+		t.Error("ParseHeaderProfile() accepted an unknown profile")
+	}
+}
@@ -29,16 +29,7 @@ type CustomResponse struct {
 	Header  http.Header
 }
 
-var (
-	Client         HTTPClient
-	defaultHeaders = http.Header{
-		"Content-Type": {"application/json"},
-		"User-Agent":   {"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:132.0) Gecko/20100101 Firefox/132.0"},
-		"Accept":       {"*/*"},
-		"Connection":   {"keep-alive"},
-		"Referer":      {string(consts.RefererURL)},
-	}
-)
+var Client HTTPClient
 
 type transportWithAuth struct {
 	// authToken is the authentication token used for authorized requests.
@@ -47,21 +38,13 @@ type transportWithAuth struct {
 	rt http.RoundTripper
 }
 
-func addDefaultHeadersToRequest(req *http.Request) {
-	for key, values := range defaultHeaders {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
-	}
-}
-
 func Request(method string, url string, payload []byte) (*types.LoginResponse, error) {
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	addDefaultHeadersToRequest(req)
+	addProfileHeaders(req)
 
 	resp, err := Client.Do(req)
 	if err != nil {
@@ -71,6 +54,9 @@ func Request(method string, url string, payload []byte) (*types.LoginResponse, e
 
 	// Check for empty body
 	if resp.ContentLength == 0 || resp.Body == http.NoBody {
+		if resp.StatusCode >= 400 {
+			return nil, newStatusError(resp, nil)
+		}
 		return &types.LoginResponse{}, nil // return an empty LoginResponse
 	}
 
@@ -80,14 +66,59 @@ func Request(method string, url string, payload []byte) (*types.LoginResponse, e
 		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
 
+	if resp.StatusCode >= 400 {
+		return nil, newStatusError(resp, body)
+	}
+
 	var loginResponse types.LoginResponse
 	if err := json.Unmarshal(body, &loginResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal response body: %v (status %d: %s)", err, resp.StatusCode, bodySnippet(body))
 	}
 
 	return &loginResponse, nil
 }
 
+// RequestJSON sends the request and decodes a successful JSON response into out.
+// Non-2xx responses are returned as an error that includes the (redacted) body.
+func RequestJSON(method string, url string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewBuffer(payload)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	addProfileHeaders(req)
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newStatusError(resp, respBody)
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %v (status %d: %s)", err, resp.StatusCode, bodySnippet(respBody))
+	}
+
+	return nil
+}
+
 func CookieRequest(method string, url string, payload []byte) (*types.LoginResponse, error) {
 
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(payload))
@@ -95,7 +126,7 @@ func CookieRequest(method string, url string, payload []byte) (*types.LoginRespo
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	addDefaultHeadersToRequest(req)
+	addProfileHeaders(req)
 
 	resp, err := Client.Do(req)
 
@@ -106,7 +137,8 @@ func CookieRequest(method string, url string, payload []byte) (*types.LoginRespo
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status: %v", resp.Status)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, body)
 	}
 
 	return MapCookiesToResponse(resp.Cookies())
@@ -147,11 +179,13 @@ func MapCookiesToResponse(cookies []*http.Cookie) (*types.LoginResponse, error)
 // - A pointer to the initialized http.Client.
 // - An error if any issues occur during client initialization (returns nil in this implementation).
 func InitClient(authToken string) error {
+	Jar = NewSessionJar()
 	Client = &http.Client{
 		Transport: &transportWithAuth{
 			authToken: authToken,
-			rt:        http.DefaultTransport,
+			rt:        baseTransport(),
 		},
+		Jar: Jar,
 	}
 
 	return nil
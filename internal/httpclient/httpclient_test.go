@@ -5,9 +5,9 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
-	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ondrovic/bambulab-authenticator/internal/types"
 	"github.com/stretchr/testify/assert"
@@ -125,24 +125,6 @@ func TestRoundTrip_WithoutAuthToken(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status code should be OK")
 }
 
-func TestAddDefaultHeadersToRequest(t *testing.T) {
-	req, err := http.NewRequest("GET", "http://example.com", nil)
-	if err != nil {
-		t.Fatalf("Failed to create request: %v", err)
-	}
-
-	// Call func to add default headers
-	addDefaultHeadersToRequest(req)
-
-	// define expected headers
-	expectedHeaders := defaultHeaders
-
-	// Compare the headers
-	if !reflect.DeepEqual(req.Header, expectedHeaders) {
-		t.Errorf("Headers do not match. Expected: %v, got: %v", expectedHeaders, req.Header)
-	}
-}
-
 func TestMapCookiesToResponse(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -263,6 +245,16 @@ func TestRequest(t *testing.T) {
 			}),
 			expectedError: "failed to unmarshal response body: invalid character 'i' looking for beginning of value",
 		},
+		{
+			name:    "Error status",
+			method:  http.MethodPost,
+			url:     "http://example.com/login",
+			payload: nil,
+			mockResponse: createMockResponse(http.StatusBadRequest, `{"error":"Incorrect code"}`, map[string]string{
+				"Content-Type": "application/json",
+			}),
+			expectedError: "request failed with status: 400",
+		},
 	}
 
 	for _, tt := range tests {
@@ -302,3 +294,41 @@ func TestRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           int
+		body             string
+		retryAfter       string
+		expectedMessage  string
+		expectedRetry    time.Duration
+		expectedRejected bool
+	}{
+		{name: "Error field", status: http.StatusBadRequest, body: `{"error":"Incorrect code"}`, expectedMessage: "Incorrect code", expectedRejected: true},
+		{name: "Message field", status: http.StatusUnauthorized, body: `{"message":"Wrong password"}`, expectedMessage: "Wrong password", expectedRejected: true},
+		{name: "Rate limited", status: http.StatusTooManyRequests, body: `{}`, retryAfter: "30", expectedRetry: 30 * time.Second},
+		{name: "Server error", status: http.StatusBadGateway, body: `bad gateway`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := createMockResponse(tt.status, tt.body, nil)
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			statusErr := newStatusError(resp, []byte(tt.body))
+
+			if statusErr.Message != tt.expectedMessage {
+				t.Errorf("Message = %q, expected %q", statusErr.Message, tt.expectedMessage)
+			}
+			if statusErr.RetryAfter != tt.expectedRetry {
+				t.Errorf("RetryAfter = %v, expected %v", statusErr.RetryAfter, tt.expectedRetry)
+			}
+			if statusErr.Rejected() != tt.expectedRejected {
+				t.Errorf("Rejected() = %v, expected %v", statusErr.Rejected(), tt.expectedRejected)
+			}
+		})
+	}
+}
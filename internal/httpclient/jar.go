@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Jar holds the cookies of the current session so the web endpoints see one browser-like session
+// across the login steps. InitClient and InitRefreshingClient give every new client a fresh jar.
+var Jar *SessionJar
+
+// SessionJar is an http.CookieJar that can be saved to and restored from a file.
+type SessionJar struct {
+	mu  sync.Mutex
+	jar *cookiejar.Jar
+	// saved remembers every cookie set, cookiejar.Jar cannot list its content.
+	saved map[string]savedCookie
+}
+
+// savedCookie is a cookie together with the URL that set it, as written to the cookie file.
+type savedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// NewSessionJar returns an empty jar.
+func NewSessionJar() *SessionJar {
+	// cookiejar.New only fails on invalid options
+	jar, _ := cookiejar.New(nil)
+
+	return &SessionJar{jar: jar, saved: map[string]savedCookie{}}
+}
+
+// SetCookies stores the cookies received from u.
+func (j *SessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	for _, cookie := range cookies {
+		key := u.Hostname() + "|" + cookie.Domain + "|" + cookie.Path + "|" + cookie.Name
+
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 || (!expires.IsZero() && expires.Before(now)) {
+			delete(j.saved, key)
+			continue
+		}
+
+		j.saved[key] = savedCookie{
+			URL:      origin.String(),
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u.
+func (j *SessionJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.jar.Cookies(u)
+}
+
+// Save writes the unexpired cookies to path, readable by the owner only.
+func (j *SessionJar) Save(path string) error {
+	j.mu.Lock()
+	now := time.Now()
+	cookies := []savedCookie{}
+	for _, cookie := range j.saved {
+		if cookie.Expires.IsZero() || cookie.Expires.After(now) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	j.mu.Unlock()
+
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookies: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cookies: %v", err)
+	}
+
+	return nil
+}
+
+// Load adds the unexpired cookies saved in path to the jar.
+func (j *SessionJar) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read cookies: %v", err)
+	}
+
+	var cookies []savedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return fmt.Errorf("failed to parse cookies: %v", err)
+	}
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+
+		u, err := url.Parse(cookie.URL)
+		if err != nil {
+			return fmt.Errorf("failed to parse cookie url: %v", err)
+		}
+
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}})
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientSendsCookiesBack(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sign-in" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			return
+		}
+		if cookie, err := r.Cookie("session"); err == nil {
+			received = cookie.Value
+		}
+	}))
+	defer server.Close()
+
+	if err := InitClient(""); err != nil {
+		t.Fatalf("InitClient() error = %v", err)
+	}
+
+	if _, err := Request(http.MethodPost, server.URL+"/sign-in", nil); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if _, err := Request(http.MethodPost, server.URL+"/tfa", nil); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+
+	if received != "abc" {
+		t.Errorf("second request sent cookie %q, expected the one set by the first", received)
+	}
+}
+
+func TestSessionJarSaveAndLoad(t *testing.T) {
+	u, _ := url.Parse("https://bambulab.com/api/sign-in/tfa")
+	jar := NewSessionJar()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "token", Value: "access", Path: "/", MaxAge: 3600},
+		{Name: "session", Value: "browser", Path: "/"},
+		{Name: "expired", Value: "old", Path: "/", Expires: time.Now().Add(-time.Hour)},
+	})
+	// A later deletion removes the cookie from the saved ones
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Path: "/", MaxAge: -1}})
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := jar.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	restored := NewSessionJar()
+	if err := restored.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	cookies := map[string]string{}
+	for _, cookie := range restored.Cookies(u) {
+		cookies[cookie.Name] = cookie.Value
+	}
+	expected := map[string]string{"token": "access"}
+	if len(cookies) != len(expected) || cookies["token"] != "access" {
+		t.Errorf("restored cookies = %v, expected %v", cookies, expected)
+	}
+}
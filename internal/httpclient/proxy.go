@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// defaultProxyPorts are used when an http(s) proxy url has no port.
+var defaultProxyPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Dial opens a TCP connection to addr, through the configured proxy when there is one for it. SOCKS5
+// proxies are dialed with golang.org/x/net/proxy, HTTP(S) proxies are asked for a CONNECT tunnel. The
+// proxy is chosen as for an https request to addr, so HTTPS_PROXY and NO_PROXY apply when --proxy is
+// not given.
+func Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	proxyURL, err := proxyFor(addr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return dialer.Dial("tcp", addr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		return dialSOCKS(ctx, proxyURL, dialer, addr)
+	default:
+		return dialConnect(ctx, proxyURL, dialer, addr)
+	}
+}
+
+func proxyFor(addr string) (*url.URL, error) {
+	rt, ok := transport.(*http.Transport)
+	if !ok || rt.Proxy == nil {
+		return nil, nil
+	}
+
+	proxyURL, err := rt.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose proxy: %v", err)
+	}
+
+	return proxyURL, nil
+}
+
+func dialSOCKS(ctx context.Context, proxyURL *url.URL, dialer *net.Dialer, addr string) (net.Conn, error) {
+	socks, err := proxy.FromURL(proxyURL, dialer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socks proxy: %v", err)
+	}
+
+	conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks proxy connection failed: %v", err)
+	}
+
+	return conn, nil
+}
+
+// dialConnect opens a CONNECT tunnel to addr through an http or https proxy.
+func dialConnect(ctx context.Context, proxyURL *url.URL, dialer *net.Dialer, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), defaultProxyPorts[proxyURL.Scheme])
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %v", err)
+	}
+
+	if proxyURL.Scheme == "https" {
+		config := TLSConfig()
+		config.ServerName = proxyURL.Hostname()
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake failed: %v", err)
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	err = httpConnect(conn, proxyURL.User, addr)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func httpConnect(conn net.Conn, user *url.Userinfo, addr string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("failed to send CONNECT to proxy: %v", err)
+	}
+
+	// The other end stays silent until the client speaks, so nothing is left in the buffer
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("failed to read proxy response: %v", err)
+	}
+
+	// After a 200 the connection belongs to the tunnel, there is no body to read
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return fmt.Errorf("proxy refused CONNECT: %v", resp.Status)
+	}
+
+	return nil
+}
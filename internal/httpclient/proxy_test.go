@@ -0,0 +1,226 @@
+package httpclient
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer answers every connection by echoing one line back.
+func echoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte(line))
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// pipe connects a and b in both directions until either side closes.
+func pipe(a net.Conn, b net.Conn) {
+	go func() { io.Copy(a, b); a.Close() }()
+	io.Copy(b, a)
+	b.Close()
+}
+
+func connectProxy(t *testing.T, authorization *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*authorization = r.Header.Get("Proxy-Authorization")
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, target)
+	}))
+}
+
+// socksProxy accepts username/password authentication and connects to the requested domain name.
+func socksProxy(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, 512)
+
+		io.ReadFull(conn, buf[:2])
+		io.ReadFull(conn, buf[:buf[1]])
+		conn.Write([]byte{0x05, 0x02})
+
+		io.ReadFull(conn, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		password := make([]byte, buf[0])
+		io.ReadFull(conn, password)
+		if string(user) != "user" || string(password) != "secret" {
+			conn.Write([]byte{0x01, 0x01})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+
+		io.ReadFull(conn, buf[:5])
+		host := make([]byte, buf[4])
+		io.ReadFull(conn, host)
+		io.ReadFull(conn, buf[:2])
+		port := int(buf[0])<<8 | int(buf[1])
+
+		target, err := net.Dial("tcp", net.JoinHostPort(string(host), strconv.Itoa(port)))
+		if err != nil {
+			conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x03, 0x01, 'x', 0, 0})
+		pipe(conn, target)
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestDial(t *testing.T) {
+	target := echoServer(t)
+	_, targetPort, _ := net.SplitHostPort(target)
+
+	var authorization string
+	httpProxy := connectProxy(t, &authorization)
+	defer httpProxy.Close()
+
+	tests := []struct {
+		name          string
+		proxy         string
+		addr          string
+		authorization string
+	}{
+		{name: "Direct", addr: target},
+		{name: "HTTP CONNECT", proxy: httpProxy.URL, addr: target},
+		{name: "HTTP CONNECT with credentials", proxy: "http://user:secret@" + httpProxy.Listener.Addr().String(), addr: target, authorization: "Basic dXNlcjpzZWNyZXQ="},
+		{name: "SOCKS5 with credentials", proxy: "socks5://user:secret@" + socksProxy(t), addr: "localhost:" + targetPort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorization = ""
+			if err := useTransport(t, TransportOptions{Proxy: tt.proxy}); err != nil {
+				t.Fatalf("ConfigureTransport() error = %v", err)
+			}
+
+			conn, err := Dial(tt.addr, 5*time.Second)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			conn.Write([]byte("ping\n"))
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil || line != "ping\n" {
+				t.Errorf("read %q, %v, expected the echo", line, err)
+			}
+			if authorization != tt.authorization {
+				t.Errorf("Proxy-Authorization = %q, expected %q", authorization, tt.authorization)
+			}
+		})
+	}
+}
+
+func TestDialSocksRejectsCredentials(t *testing.T) {
+	if err := useTransport(t, TransportOptions{Proxy: "socks5://user:wrong@" + socksProxy(t)}); err != nil {
+		t.Fatalf("ConfigureTransport() error = %v", err)
+	}
+
+	if _, err := Dial(echoServer(t), 5*time.Second); err == nil {
+		t.Error("Dial() succeeded with rejected credentials")
+	}
+}
+
+// closedAddr returns an address nothing listens on.
+func closedAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestDialErrorReplies(t *testing.T) {
+	var authorization string
+	httpProxy := connectProxy(t, &authorization)
+	defer httpProxy.Close()
+
+	authProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+		w.WriteHeader(http.StatusProxyAuthRequired)
+		w.Write([]byte("authentication required"))
+	}))
+	defer authProxy.Close()
+
+	_, closedPort, _ := net.SplitHostPort(closedAddr(t))
+
+	tests := []struct {
+		name    string
+		proxy   string
+		addr    string
+		wantErr string
+	}{
+		{name: "CONNECT needs proxy credentials", proxy: authProxy.URL, addr: echoServer(t), wantErr: "proxy refused CONNECT: 407"},
+		{name: "CONNECT target unreachable", proxy: httpProxy.URL, addr: closedAddr(t), wantErr: "proxy refused CONNECT: 502"},
+		{name: "Proxy unreachable", proxy: "http://" + closedAddr(t), addr: echoServer(t), wantErr: "failed to connect to proxy"},
+		{name: "SOCKS5 target unreachable", proxy: "socks5://user:secret@" + socksProxy(t), addr: "localhost:" + closedPort, wantErr: "socks proxy connection failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useTransport(t, TransportOptions{Proxy: tt.proxy}); err != nil {
+				t.Fatalf("ConfigureTransport() error = %v", err)
+			}
+
+			conn, err := Dial(tt.addr, 5*time.Second)
+			if err == nil {
+				conn.Close()
+				t.Fatal("Dial() succeeded, expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Dial() error = %v, expected it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
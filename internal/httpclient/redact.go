@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secret values in traces and error messages.
+const Redacted = "[REDACTED]"
+
+// maxBodySnippet limits how much of a body is echoed in error messages.
+const maxBodySnippet = 512
+
+// secretKeys are JSON fields, query parameters and cookie names whose values are never logged, in
+// the form normalizeKey gives them.
+var secretKeys = map[string]bool{
+	"password":      true,
+	"code":          true,
+	"tfacode":       true,
+	"tfakey":        true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"accesscode":    true,
+	"devaccesscode": true,
+}
+
+// secretHeaders are headers whose values are never logged.
+var secretHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// normalizeKey lowercases a key and drops separators, so dev_access_code and devAccessCode match alike.
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+func isSecretKey(key string) bool {
+	return secretKeys[normalizeKey(key)]
+}
+
+// redactHeaders returns a copy of the headers with credentials removed. Cookie names are kept.
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name, values := range redacted {
+		if !secretHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for i, value := range values {
+			redacted[name][i] = redactHeaderValue(http.CanonicalHeaderKey(name), value)
+		}
+	}
+
+	return redacted
+}
+
+func redactHeaderValue(name string, value string) string {
+	switch name {
+	case "Set-Cookie":
+		cookieName, _, _ := strings.Cut(value, "=")
+		return cookieName + "=" + Redacted
+	case "Cookie":
+		pairs := strings.Split(value, ";")
+		for i, pair := range pairs {
+			cookieName, _, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			pairs[i] = cookieName + "=" + Redacted
+		}
+		return strings.Join(pairs, "; ")
+	default:
+		return Redacted
+	}
+}
+
+// redactURL hides secret query parameters.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if isSecretKey(key) {
+			query.Set(key, Redacted)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return u.String()
+	}
+
+	clone := *u
+	clone.RawQuery = query.Encode()
+	return clone.String()
+}
+
+// redactBody hides secret fields of a JSON body. Bodies that are not JSON are returned unchanged.
+func redactBody(body []byte) []byte {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return body
+	}
+
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return body
+	}
+
+	return redacted
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if isSecretKey(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactValue(inner)
+		}
+		return v
+	case []any:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// bodySnippet returns a redacted, truncated body suitable for error messages.
+func bodySnippet(body []byte) string {
+	snippet := bytes.TrimSpace(redactBody(body))
+	if len(snippet) > maxBodySnippet {
+		return string(snippet[:maxBodySnippet]) + "..."
+	}
+
+	return string(snippet)
+}
@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Login payload",
+			body:     `{"account":"user@example.com","password":"secret","apiError":""}`,
+			expected: `{"account":"user@example.com","apiError":"","password":"[REDACTED]"}`,
+		},
+		{
+			name:     "Nested tokens",
+			body:     `{"data":[{"accessToken":"abc","refreshToken":"def","expiresIn":3600}]}`,
+			expected: `{"data":[{"accessToken":"[REDACTED]","expiresIn":3600,"refreshToken":"[REDACTED]"}]}`,
+		},
+		{
+			name:     "Two factor payload",
+			body:     `{"tfaKey":"key","tfaCode":"123456"}`,
+			expected: `{"tfaCode":"[REDACTED]","tfaKey":"[REDACTED]"}`,
+		},
+		{
+			name:     "Not JSON",
+			body:     `<html>error</html>`,
+			expected: `<html>error</html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(redactBody([]byte(tt.body))))
+		})
+	}
+}
+
+func TestRedactBindResponse(t *testing.T) {
+	body, err := json.Marshal(types.DevicesResponse{Devices: []types.Device{
+		{Serial: "01P00A000000001", Name: "Workshop", AccessCode: "12345678"},
+		{Serial: "01P00A000000002", Name: "Office", AccessCode: "87654321"},
+	}})
+	require.NoError(t, err)
+	require.Contains(t, string(body), "dev_access_code")
+
+	redacted := string(redactBody(body))
+	assert.NotContains(t, redacted, "12345678")
+	assert.NotContains(t, redacted, "87654321")
+	assert.Contains(t, redacted, `"dev_access_code":"[REDACTED]"`)
+	assert.Contains(t, redacted, "01P00A000000001", "The serial is not a secret")
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{
+		"Authorization": {"token abc"},
+		"Cookie":        {"token=abc; refreshToken=def"},
+		"Set-Cookie":    {"token=abc; Path=/; HttpOnly"},
+		"Content-Type":  {"application/json"},
+	}
+
+	redacted := redactHeaders(header)
+
+	assert.Equal(t, Redacted, redacted.Get("Authorization"))
+	assert.Equal(t, "token=[REDACTED]; refreshToken=[REDACTED]", redacted.Get("Cookie"))
+	assert.Equal(t, "token=[REDACTED]", redacted.Get("Set-Cookie"))
+	assert.Equal(t, "application/json", redacted.Get("Content-Type"))
+	assert.Equal(t, "token abc", header.Get("Authorization"), "Original headers should be untouched")
+}
+
+func TestRedactURL(t *testing.T) {
+	u, err := url.Parse("https://example.com/path?code=123456&lang=en")
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.com/path?code=%5BREDACTED%5D&lang=en", redactURL(u))
+}
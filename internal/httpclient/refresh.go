@@ -0,0 +1,233 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// RefreshSkew is how long before the access token expires that it is proactively refreshed.
+const RefreshSkew = 5 * time.Minute
+
+// TokenSaver persists tokens obtained by a refresh so later runs pick them up.
+type TokenSaver func(tokens types.LoginResponse) error
+
+// refreshingTransport adds the access token to each request and refreshes it
+// with the stored refresh token when it is about to expire or the API answers 401.
+type refreshingTransport struct {
+	// mu serializes refreshes so concurrent requests only trigger one.
+	mu sync.Mutex
+	// tokens is the current token set.
+	tokens types.LoginResponse
+	// refreshURL is the endpoint used to exchange the refresh token.
+	refreshURL string
+	// save persists refreshed tokens, may be nil.
+	save TokenSaver
+	// now returns the current time, overridable in tests.
+	now func() time.Time
+	// rt is the underlying RoundTripper used for HTTP transport.
+	rt http.RoundTripper
+}
+
+// InitRefreshingClient initializes the HTTP client with a transport that authenticates
+// requests with the given tokens and transparently refreshes them.
+// Parameters:
+// - tokens: The saved token set, its refresh token is used to obtain new access tokens.
+// - refreshURL: The (regional) refresh token endpoint.
+// - save: Called with the new token set after every successful refresh, may be nil.
+// Returns:
+// - An error if any issues occur during client initialization (returns nil in this implementation).
+func InitRefreshingClient(tokens types.LoginResponse, refreshURL string, save TokenSaver) error {
+	Jar = NewSessionJar()
+	Client = &http.Client{
+		Transport: newRefreshingTransport(tokens, refreshURL, save, baseTransport()),
+		Jar:       Jar,
+	}
+
+	return nil
+}
+
+func newRefreshingTransport(tokens types.LoginResponse, refreshURL string, save TokenSaver, rt http.RoundTripper) *refreshingTransport {
+	return &refreshingTransport{
+		tokens:     tokens,
+		refreshURL: refreshURL,
+		save:       save,
+		now:        time.Now,
+		rt:         rt,
+	}
+}
+
+// RoundTrip sends the request with the current access token. The token is refreshed
+// first when it is close to expiry, and at most once more if the server replies 401,
+// in which case the original request is retried with the new token.
+func (t *refreshingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The body has already been consumed and cannot be replayed
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	newToken, err := t.refresh(token)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	return t.send(req, newToken)
+}
+
+// ActiveTokens returns the token set of a client initialized with InitRefreshingClient,
+// refreshing it first when it is about to expire.
+func ActiveTokens() (*types.LoginResponse, error) {
+	client, ok := Client.(*http.Client)
+	if !ok {
+		return nil, fmt.Errorf("client does not manage tokens")
+	}
+
+	transport, ok := client.Transport.(*refreshingTransport)
+	if !ok {
+		return nil, fmt.Errorf("client does not manage tokens")
+	}
+
+	if _, err := transport.currentToken(); err != nil {
+		return nil, err
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	tokens := transport.tokens
+
+	return &tokens, nil
+}
+
+// send clones the request, attaches the token and passes it to the underlying transport.
+func (t *refreshingTransport) send(req *http.Request, token string) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %v", err)
+		}
+		clone.Body = body
+	}
+
+	if token != "" {
+		clone.Header.Set("Authorization", "token "+token)
+	}
+
+	return t.rt.RoundTrip(clone)
+}
+
+// currentToken returns the access token, refreshing it first if it is about to expire.
+func (t *refreshingTransport) currentToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if t.tokens.ExpiresWithin(now, RefreshSkew) && t.tokens.CanRefresh(now) {
+		if err := t.refreshLocked(); err != nil {
+			return "", err
+		}
+	}
+
+	return t.tokens.AccessToken, nil
+}
+
+// refresh obtains a new access token unless another request already replaced the stale one.
+func (t *refreshingTransport) refresh(stale string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tokens.AccessToken != stale {
+		return t.tokens.AccessToken, nil
+	}
+
+	if !t.tokens.CanRefresh(t.now()) {
+		return "", fmt.Errorf("token refresh failed: no valid refresh token")
+	}
+
+	if err := t.refreshLocked(); err != nil {
+		return "", err
+	}
+
+	return t.tokens.AccessToken, nil
+}
+
+// refreshLocked exchanges the refresh token for a new token set and persists it. Callers must hold mu.
+func (t *refreshingTransport) refreshLocked() error {
+	payload, err := json.Marshal(types.RefreshTokenPayload{RefreshToken: t.tokens.RefreshToken})
+	if err != nil {
+		return fmt.Errorf("failed to marshal refreshTokenPayload: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.refreshURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create refresh request: %v", err)
+	}
+
+	addProfileHeaders(req)
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("token refresh failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token refresh failed with status: %v", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read refresh response body: %v", err)
+	}
+
+	var refreshed types.LoginResponse
+	if err := json.Unmarshal(body, &refreshed); err != nil {
+		return fmt.Errorf("failed to unmarshal refresh response body: %v", err)
+	}
+
+	if refreshed.AccessToken == "" {
+		return fmt.Errorf("token refresh failed: response did not contain an access token")
+	}
+
+	refreshed.StampExpiry(t.now())
+
+	refreshed.Account = t.tokens.Account
+	refreshed.Region = t.tokens.Region
+
+	// Some responses only rotate the access token
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = t.tokens.RefreshToken
+		refreshed.RefreshExpiresIn = t.tokens.RefreshExpiresIn
+		refreshed.RefreshExpiresAt = t.tokens.RefreshExpiresAt
+	}
+
+	// A token set the save callback refuses must not be used either, so the old one is kept
+	if t.save != nil {
+		if err := t.save(refreshed); err != nil {
+			return fmt.Errorf("failed to persist refreshed tokens: %v", err)
+		}
+	}
+
+	t.tokens = refreshed
+
+	return nil
+}
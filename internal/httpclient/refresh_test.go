@@ -0,0 +1,194 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRefreshTestServer returns a server whose /api endpoint only accepts validToken and
+// whose /refresh endpoint hands out "fresh-token", counting refreshes.
+func newRefreshTestServer(t *testing.T, refreshes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/refresh":
+			atomic.AddInt32(refreshes, 1)
+			var payload types.RefreshTokenPayload
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "refresh-token", payload.RefreshToken)
+			// Slow down so concurrent callers pile up behind the lock
+			time.Sleep(20 * time.Millisecond)
+			json.NewEncoder(w).Encode(types.LoginResponse{
+				AccessToken:      "fresh-token",
+				RefreshToken:     "refresh-token",
+				ExpiresIn:        3600,
+				RefreshExpiresIn: 7200,
+			})
+		case "/api":
+			if r.Header.Get("Authorization") != "token fresh-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		}
+	}))
+}
+
+func TestRefreshingTransport_RetriesAfterUnauthorized(t *testing.T) {
+	var refreshes int32
+	server := newRefreshTestServer(t, &refreshes)
+	defer server.Close()
+
+	var saved []types.LoginResponse
+	transport := newRefreshingTransport(
+		types.LoginResponse{AccessToken: "stale-token", RefreshToken: "refresh-token"},
+		server.URL+"/refresh",
+		func(tokens types.LoginResponse) error {
+			saved = append(saved, tokens)
+			return nil
+		},
+		http.DefaultTransport,
+	)
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api", bytes.NewBufferString("payload"))
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Retried request should succeed")
+	assert.Equal(t, "payload", string(body), "Retried request should replay the original body")
+	assert.Equal(t, int32(1), refreshes, "Token should be refreshed once")
+	require.Len(t, saved, 1, "Refreshed tokens should be persisted")
+	assert.Equal(t, "fresh-token", saved[0].AccessToken)
+	assert.NotZero(t, saved[0].ExpiresAt, "Refreshed tokens should carry an absolute expiry")
+}
+
+func TestRefreshingTransport_RefreshesNearExpiry(t *testing.T) {
+	var refreshes int32
+	server := newRefreshTestServer(t, &refreshes)
+	defer server.Close()
+
+	now := time.Now()
+	transport := newRefreshingTransport(
+		types.LoginResponse{
+			AccessToken:  "stale-token",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    now.Add(time.Minute).Unix(),
+		},
+		server.URL+"/refresh",
+		nil,
+		http.DefaultTransport,
+	)
+
+	var unauthorized int32
+	transport.rt = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api" && req.Header.Get("Authorization") != "token fresh-token" {
+			atomic.AddInt32(&unauthorized, 1)
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL + "/api")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), refreshes, "Token should be refreshed before the request")
+	assert.Equal(t, int32(0), unauthorized, "Stale token should never be sent")
+}
+
+func TestRefreshingTransport_SerializesConcurrentRefreshes(t *testing.T) {
+	var refreshes int32
+	server := newRefreshTestServer(t, &refreshes)
+	defer server.Close()
+
+	transport := newRefreshingTransport(
+		types.LoginResponse{AccessToken: "stale-token", RefreshToken: "refresh-token"},
+		server.URL+"/refresh",
+		nil,
+		http.DefaultTransport,
+	)
+	client := &http.Client{Transport: transport}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/api")
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), refreshes, "Concurrent requests should share a single refresh")
+}
+
+func TestRefreshingTransport_NoRefreshToken(t *testing.T) {
+	var refreshes int32
+	server := newRefreshTestServer(t, &refreshes)
+	defer server.Close()
+
+	transport := newRefreshingTransport(
+		types.LoginResponse{AccessToken: "stale-token"},
+		server.URL+"/refresh",
+		nil,
+		http.DefaultTransport,
+	)
+	client := &http.Client{Transport: transport}
+
+	_, err := client.Get(server.URL + "/api")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no valid refresh token")
+	assert.Equal(t, int32(0), refreshes)
+}
+
+func TestRefreshingTransport_KeepsTokensWhenSaveFails(t *testing.T) {
+	var refreshes int32
+	server := newRefreshTestServer(t, &refreshes)
+	defer server.Close()
+
+	transport := newRefreshingTransport(
+		types.LoginResponse{AccessToken: "stale-token", RefreshToken: "refresh-token"},
+		server.URL+"/refresh",
+		func(tokens types.LoginResponse) error {
+			return errors.New("the login response is unusable")
+		},
+		http.DefaultTransport,
+	)
+	client := &http.Client{Transport: transport}
+
+	_, err := client.Get(server.URL + "/api")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to persist refreshed tokens")
+	assert.Equal(t, "stale-token", transport.tokens.AccessToken, "Refused tokens must not replace the current ones")
+}
+
+// roundTripFunc adapts a function to the http.RoundTripper interface.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned when the server answers with a 4xx or 5xx status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Message is the error message found in the response body, if any.
+	Message string
+	// RetryAfter is the delay asked for by a Retry-After header, zero when absent.
+	RetryAfter time.Duration
+	// snippet is the redacted response body.
+	snippet string
+}
+
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	statusErr := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		snippet:    bodySnippet(body),
+	}
+
+	var message struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &message) == nil {
+		statusErr.Message = message.Error
+		if statusErr.Message == "" {
+			statusErr.Message = message.Message
+		}
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return statusErr
+}
+
+func (e *StatusError) Error() string {
+	if e.snippet == "" {
+		return fmt.Sprintf("request failed with status: %v", e.Status)
+	}
+
+	return fmt.Sprintf("request failed with status: %v (%s)", e.Status, e.snippet)
+}
+
+// Rejected reports whether the server refused the request itself, as opposed to being
+// unavailable or rate limiting it.
+func (e *StatusError) Rejected() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests
+}
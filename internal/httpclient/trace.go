@@ -0,0 +1,342 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.szostok.io/version"
+)
+
+// TraceOptions controls how requests and responses are traced.
+type TraceOptions struct {
+	// Logger receives a debug record per exchange. Nothing is logged when nil.
+	Logger *slog.Logger
+	// Bodies includes headers and bodies in the logged records, not just the summary.
+	Bodies bool
+	// HARPath writes every exchange to a HAR file when set.
+	HARPath string
+}
+
+// tracer is the active tracer, nil when tracing is disabled.
+var tracer *Tracer
+
+// Tracer records redacted HTTP exchanges.
+type Tracer struct {
+	opts    TraceOptions
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// EnableTracing turns on tracing for clients initialized afterwards.
+// Passing empty options disables it again.
+func EnableTracing(opts TraceOptions) {
+	if opts.Logger == nil && opts.HARPath == "" {
+		tracer = nil
+		return
+	}
+
+	tracer = &Tracer{opts: opts}
+}
+
+// baseTransport returns the transport clients send requests through, wrapped by the tracer when enabled.
+func baseTransport() http.RoundTripper {
+	if tracer == nil {
+		return transport
+	}
+
+	return &tracingTransport{tracer: tracer, rt: transport}
+}
+
+// tracingTransport records each request and response passing through it.
+type tracingTransport struct {
+	tracer *Tracer
+	// rt is the underlying RoundTripper used for HTTP transport.
+	rt http.RoundTripper
+}
+
+// RoundTrip sends the request and records it together with the response and timing.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := peekRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	elapsed := time.Since(started)
+
+	if err != nil {
+		t.tracer.record(req, reqBody, nil, nil, started, elapsed, err)
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.tracer.record(req, reqBody, resp, respBody, started, elapsed, nil)
+
+	return resp, nil
+}
+
+// peekRequestBody reads the request body without consuming it.
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func (t *Tracer) record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, elapsed time.Duration, roundTripErr error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.opts.Logger != nil {
+		t.log(req, reqBody, resp, respBody, elapsed, roundTripErr)
+	}
+
+	if t.opts.HARPath != "" {
+		t.entries = append(t.entries, newHAREntry(req, reqBody, resp, respBody, started, elapsed))
+		if err := t.writeHAR(); err != nil {
+			slog.Error("failed to write HAR file", "path", t.opts.HARPath, "error", err)
+		}
+	}
+}
+
+func (t *Tracer) log(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, elapsed time.Duration, roundTripErr error) {
+	attrs := []any{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Duration("duration", elapsed.Round(time.Millisecond)),
+	}
+
+	if t.opts.Bodies {
+		attrs = append(attrs, slog.Group("request",
+			slog.Any("headers", flattenHeaders(req.Header)),
+			slog.String("body", bodyText(reqBody)),
+		))
+	}
+
+	if roundTripErr != nil {
+		t.opts.Logger.Debug("http request failed", append(attrs, slog.Any("error", roundTripErr))...)
+		return
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if t.opts.Bodies {
+		attrs = append(attrs, slog.Group("response",
+			slog.Any("headers", flattenHeaders(resp.Header)),
+			slog.String("body", bodyText(respBody)),
+		))
+	}
+
+	t.opts.Logger.Debug("http request", attrs...)
+}
+
+// flattenHeaders returns the redacted headers with multiple values joined.
+func flattenHeaders(header http.Header) map[string]string {
+	flattened := map[string]string{}
+	for name, values := range redactHeaders(header) {
+		flattened[name] = strings.Join(values, ", ")
+	}
+
+	return flattened
+}
+
+func bodyText(body []byte) string {
+	return string(bytes.TrimSpace(redactBody(body)))
+}
+
+// The har* types are the subset of the HAR 1.2 format (http://www.softwareishard.com/blog/har-12-spec/) we produce.
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harNameVal `json:"headers"`
+	QueryString []harNameVal `json:"queryString"`
+	Cookies     []harNameVal `json:"cookies"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+	PostData    *harPostData `json:"postData,omitempty"`
+}
+
+type harResponse struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harNameVal `json:"headers"`
+	Cookies     []harNameVal `json:"cookies"`
+	Content     harContent   `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harNameVal struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAREntry(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, elapsed time.Duration) harEntry {
+	millis := float64(elapsed.Microseconds()) / 1000
+
+	entry := harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            millis,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         redactURL(req.URL),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameVal{},
+			Cookies:     []harNameVal{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Headers:     []harNameVal{},
+			Cookies:     []harNameVal{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{Wait: millis},
+	}
+
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			if isSecretKey(key) {
+				value = Redacted
+			}
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameVal{Name: key, Value: value})
+		}
+	}
+
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(redactBody(reqBody)),
+		}
+	}
+
+	if resp == nil {
+		return entry
+	}
+
+	entry.Response.Status = resp.StatusCode
+	entry.Response.StatusText = http.StatusText(resp.StatusCode)
+	entry.Response.HTTPVersion = resp.Proto
+	entry.Response.Headers = harHeaders(resp.Header)
+	entry.Response.BodySize = len(respBody)
+	entry.Response.Content = harContent{
+		Size:     len(respBody),
+		MimeType: resp.Header.Get("Content-Type"),
+		Text:     string(redactBody(respBody)),
+	}
+
+	for _, cookie := range resp.Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harNameVal{Name: cookie.Name, Value: Redacted})
+	}
+
+	return entry
+}
+
+func harHeaders(header http.Header) []harNameVal {
+	redacted := redactHeaders(header)
+	headers := []harNameVal{}
+	for name, values := range redacted {
+		for _, value := range values {
+			headers = append(headers, harNameVal{Name: name, Value: value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+
+	return headers
+}
+
+// writeHAR rewrites the HAR file with every entry recorded so far, so it is complete even if the run fails.
+func (t *Tracer) writeHAR() error {
+	har := harFile{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "bambulab-authenticator", Version: version.Get().Version},
+			Entries: t.entries,
+		},
+	}
+
+	jsonData, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal HAR: %v", err)
+	}
+
+	return os.WriteFile(t.opts.HARPath, jsonData, 0600)
+}
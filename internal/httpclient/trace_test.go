@@ -0,0 +1,63 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingTransport(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: "cookie-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"accessToken":"response-secret","loginType":"tfa"}`))
+	}))
+	defer testServer.Close()
+
+	var out bytes.Buffer
+	harPath := filepath.Join(t.TempDir(), "trace.har")
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	EnableTracing(TraceOptions{Logger: logger, Bodies: true, HARPath: harPath})
+	defer EnableTracing(TraceOptions{})
+
+	require.NoError(t, InitClient("auth-secret"))
+
+	result, err := Request(http.MethodPost, testServer.URL, []byte(`{"account":"user","password":"password-secret"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "response-secret", result.AccessToken, "Tracing should not consume the response body")
+
+	trace := out.String()
+	assert.Contains(t, trace, "method=POST url="+testServer.URL)
+	assert.Contains(t, trace, "status=200")
+	assert.Contains(t, trace, `\"account\":\"user\"`)
+	assert.Contains(t, trace, `\"loginType\":\"tfa\"`)
+
+	har, err := os.ReadFile(harPath)
+	require.NoError(t, err)
+
+	var parsed harFile
+	require.NoError(t, json.Unmarshal(har, &parsed))
+	require.Len(t, parsed.Log.Entries, 1)
+	assert.Equal(t, http.StatusOK, parsed.Log.Entries[0].Response.Status)
+	assert.Equal(t, http.MethodPost, parsed.Log.Entries[0].Request.Method)
+
+	for name, text := range map[string]string{"trace": trace, "har": string(har)} {
+		for _, secret := range []string{"auth-secret", "password-secret", "response-secret", "cookie-secret"} {
+			assert.NotContains(t, text, secret, "%s should not contain %s", name, secret)
+		}
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	EnableTracing(TraceOptions{})
+
+	assert.Equal(t, http.DefaultTransport, baseTransport(), "No tracer should wrap the default transport")
+}
@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+)
+
+// TransportOptions configures how requests reach the Bambu cloud.
+type TransportOptions struct {
+	// Proxy is an http, https, socks5 or socks5h proxy URL. Empty uses the HTTP(S)_PROXY environment variables.
+	Proxy string
+	// CAFiles are PEM bundles trusted in addition to the system roots.
+	CAFiles []string
+	// ClientCert and ClientKey are the PEM client certificate and key. The key may be in the certificate file.
+	ClientCert string
+	ClientKey  string
+	// MinTLSVersion is the lowest accepted TLS version: 1.0, 1.1, 1.2 or 1.3. Empty keeps the Go default.
+	MinTLSVersion string
+	// Insecure disables certificate verification.
+	Insecure bool
+}
+
+// tlsVersions maps the accepted --min-tls-version values to their constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// transport is the RoundTripper every client sends requests through.
+var transport http.RoundTripper = http.DefaultTransport
+
+// ConfigureTransport applies the options to the transport of clients initialized afterwards.
+func ConfigureTransport(opts TransportOptions) error {
+	rt, err := newTransport(opts)
+	if err != nil {
+		return err
+	}
+
+	transport = rt
+
+	return nil
+}
+
+// Transport returns the configured transport without tracing or authentication, for requests that are
+// not sent to the Bambu cloud.
+func Transport() http.RoundTripper {
+	return transport
+}
+
+// TLSConfig returns a copy of the configured TLS settings, for connections that do not speak HTTP.
+func TLSConfig() *tls.Config {
+	if rt, ok := transport.(*http.Transport); ok && rt.TLSClientConfig != nil {
+		return rt.TLSClientConfig.Clone()
+	}
+
+	return &tls.Config{}
+}
+
+func newTransport(opts TransportOptions) (*http.Transport, error) {
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != consts.EMPTY_STRING {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %v", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https, socks5 or socks5h", proxyURL.Scheme)
+		}
+		rt.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.Insecure}
+
+	if len(opts.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range opts.CAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no PEM certificates found in CA file %v", file)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCert != consts.EMPTY_STRING {
+		key := opts.ClientKey
+		if key == consts.EMPTY_STRING {
+			key = opts.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if opts.ClientKey != consts.EMPTY_STRING {
+		return nil, fmt.Errorf("a client key needs a client certificate")
+	}
+
+	if opts.MinTLSVersion != consts.EMPTY_STRING {
+		version, ok := tlsVersions[strings.TrimPrefix(opts.MinTLSVersion, "v")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", opts.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	rt.TLSClientConfig = tlsConfig
+
+	return rt, nil
+}
@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTransport configures the transport for one test and restores the default afterwards.
+func useTransport(t *testing.T, opts TransportOptions) error {
+	t.Cleanup(func() { transport = http.DefaultTransport })
+
+	if err := ConfigureTransport(opts); err != nil {
+		return err
+	}
+
+	return InitClient("")
+}
+
+func TestConfigureTransportTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      TransportOptions
+		expectErr bool
+	}{
+		{name: "Unknown CA", opts: TransportOptions{}, expectErr: true},
+		{name: "Extra CA file", opts: TransportOptions{CAFiles: []string{caFile}}},
+		{name: "Insecure", opts: TransportOptions{Insecure: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useTransport(t, tt.opts); err != nil {
+				t.Fatalf("ConfigureTransport() error = %v", err)
+			}
+
+			_, err := Request(http.MethodGet, server.URL, nil)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("Request() error = %v, expected error: %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestConfigureTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	if err := useTransport(t, TransportOptions{Proxy: proxy.URL}); err != nil {
+		t.Fatalf("ConfigureTransport() error = %v", err)
+	}
+
+	if _, err := Request(http.MethodGet, "http://api.bambulab.invalid/v1/user-service/my/profile", nil); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if proxied != "http://api.bambulab.invalid/v1/user-service/my/profile" {
+		t.Errorf("proxy received %q, expected the target url", proxied)
+	}
+}
+
+func TestNewTransportOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      TransportOptions
+		expectErr bool
+		check     func(*testing.T, *http.Transport)
+	}{
+		{
+			name: "SOCKS5 proxy",
+			opts: TransportOptions{Proxy: "socks5://127.0.0.1:1080"},
+			check: func(t *testing.T, rt *http.Transport) {
+				req, _ := http.NewRequest(http.MethodGet, "https://api.bambulab.com", nil)
+				proxyURL, err := rt.Proxy(req)
+				if err != nil || proxyURL.String() != "socks5://127.0.0.1:1080" {
+					t.Errorf("Proxy() = %v, %v", proxyURL, err)
+				}
+			},
+		},
+		{name: "Unsupported proxy scheme", opts: TransportOptions{Proxy: "ftp://proxy"}, expectErr: true},
+		{
+			name: "Minimum TLS version",
+			opts: TransportOptions{MinTLSVersion: "1.3"},
+			check: func(t *testing.T, rt *http.Transport) {
+				if rt.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+					t.Errorf("MinVersion = %x, expected TLS 1.3", rt.TLSClientConfig.MinVersion)
+				}
+			},
+		},
+		{name: "Unknown TLS version", opts: TransportOptions{MinTLSVersion: "1.4"}, expectErr: true},
+		{name: "Missing CA file", opts: TransportOptions{CAFiles: []string{"/nonexistent/ca.pem"}}, expectErr: true},
+		{name: "Missing client certificate", opts: TransportOptions{ClientCert: "/nonexistent/cert.pem"}, expectErr: true},
+		{name: "Key without certificate", opts: TransportOptions{ClientKey: "key.pem"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := newTransport(tt.opts)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("newTransport() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newTransport() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, rt)
+			}
+		})
+	}
+}
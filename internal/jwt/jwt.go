@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// ExpiryTolerance is how far the claimed and stored expiry may differ before it is reported.
+const ExpiryTolerance = time.Minute
+
+// timeClaims are the registered claims holding unix timestamps.
+var timeClaims = map[string]bool{"exp": true, "iat": true, "nbf": true}
+
+// Token is a decoded, unverified JWT. The signature is neither checked nor kept.
+type Token struct {
+	Header map[string]any
+	Claims map[string]any
+}
+
+// Decode splits the token and decodes its header and claims. Numbers are kept as json.Number so
+// large ids such as the uid are not rounded.
+func Decode(raw string) (*Token, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT: expected three dot separated parts")
+	}
+
+	header, err := decodePart(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token header: %v", err)
+	}
+
+	claims, err := decodePart(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+
+	return &Token{Header: header, Claims: claims}, nil
+}
+
+func decodePart(part string) (map[string]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// IsTimeClaim reports whether the claim holds a unix timestamp.
+func IsTimeClaim(name string) bool {
+	return timeClaims[name]
+}
+
+// Time returns a timestamp claim such as exp or iat.
+func (t *Token) Time(name string) (time.Time, bool) {
+	number, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds, err := number.Int64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(seconds, 0), true
+}
+
+// ExpiryMismatches compares the claimed lifetime and expiry with the ones saved in the auth file and
+// describes every difference larger than ExpiryTolerance.
+func (t *Token) ExpiryMismatches(stored *types.LoginResponse) []string {
+	exp, hasExp := t.Time("exp")
+	if !hasExp {
+		return nil
+	}
+
+	var mismatches []string
+
+	if iat, ok := t.Time("iat"); ok && stored.ExpiresIn > 0 {
+		claimed := exp.Sub(iat)
+		saved := time.Duration(stored.ExpiresIn) * time.Second
+		if absDuration(claimed-saved) > ExpiryTolerance {
+			mismatches = append(mismatches, fmt.Sprintf("token lifetime is %v but expiresIn is %v", claimed, saved))
+		}
+	}
+
+	if stored.ExpiresAt != 0 {
+		saved := time.Unix(stored.ExpiresAt, 0)
+		if absDuration(exp.Sub(saved)) > ExpiryTolerance {
+			mismatches = append(mismatches, fmt.Sprintf("token expires at %v but expiresAt is %v",
+				exp.UTC().Format(time.RFC3339), saved.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	return mismatches
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, header string, claims string) string {
+	t.Helper()
+	return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+func TestDecode(t *testing.T) {
+	raw := encode(t, `{"alg":"RS256","typ":"JWT"}`, `{"uid":12345678901234567,"iat":1700000000,"exp":1707776000,"region":"us"}`)
+
+	token, err := Decode(raw)
+	require.NoError(t, err)
+
+	assert.Equal(t, "RS256", token.Header["alg"])
+	assert.Equal(t, json.Number("12345678901234567"), token.Claims["uid"])
+	assert.Equal(t, "us", token.Claims["region"])
+
+	exp, ok := token.Time("exp")
+	require.True(t, ok)
+	assert.Equal(t, time.Unix(1707776000, 0), exp)
+
+	_, ok = token.Time("region")
+	assert.False(t, ok)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for name, raw := range map[string]string{
+		"Opaque token":   "not-a-jwt",
+		"Bad base64":     "a.!!!.c",
+		"Claims not map": "e30." + base64.RawURLEncoding.EncodeToString([]byte(`[1]`)) + ".sig",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpiryMismatches(t *testing.T) {
+	token, err := Decode(encode(t, `{}`, `{"iat":1700000000,"exp":1700003600}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		stored   types.LoginResponse
+		expected int
+	}{
+		{name: "Matching", stored: types.LoginResponse{ExpiresIn: 3600, ExpiresAt: 1700003630}},
+		{name: "Nothing stored", stored: types.LoginResponse{}},
+		{name: "Lifetime differs", stored: types.LoginResponse{ExpiresIn: 7776000}, expected: 1},
+		{name: "Both differ", stored: types.LoginResponse{ExpiresIn: 60, ExpiresAt: 1800000000}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, token.ExpiryMismatches(&tt.stored), tt.expected)
+		})
+	}
+}
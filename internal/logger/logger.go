@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level.
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
+		return parsed, fmt.Errorf("unknown log level: %v", level)
+	}
+
+	return parsed, nil
+}
+
+// New creates a logger writing to w with the given level and format (text or json).
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	parsedLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: parsedLevel}
+
+	switch strings.ToLower(format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %v", format)
+	}
+}
+
+// Init replaces the default slog logger. Diagnostics always belong on stderr so that
+// stdout only carries command results.
+func Init(w io.Writer, level string, format string) error {
+	logger, err := New(w, level, format)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(logger)
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		format    string
+		expectErr bool
+	}{
+		{
+			name:   "Text handler",
+			level:  "info",
+			format: "text",
+		},
+		{
+			name:   "JSON handler",
+			level:  "DEBUG",
+			format: "json",
+		},
+		{
+			name:      "Invalid level",
+			level:     "loud",
+			format:    "text",
+			expectErr: true,
+		},
+		{
+			name:      "Invalid format",
+			level:     "info",
+			format:    "xml",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, tt.level, tt.format)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("New() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+
+			logger.Info("hello", "key", "value")
+			if !strings.Contains(buf.String(), "hello") {
+				t.Errorf("expected message to be logged, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestNewFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single log line, got %q", buf.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("expected JSON output, got %q", lines[0])
+	}
+	if record["msg"] != "kept" || record["level"] != slog.LevelWarn.String() {
+		t.Errorf("unexpected record %v", record)
+	}
+}
@@ -0,0 +1,433 @@
+package mockserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// Scenario selects how the mock server answers a login.
+type Scenario string
+
+const (
+	// PasswordOnly returns tokens straight from the password login.
+	PasswordOnly Scenario = "password-only"
+	// EmailCode asks for a code sent by email before returning tokens.
+	EmailCode Scenario = "email-code"
+	// TOTP asks for a one-time password and returns the tokens as cookies.
+	TOTP Scenario = "totp"
+	// WrongCode behaves like EmailCode but rejects the first RejectCodes submitted codes.
+	WrongCode Scenario = "wrong-code"
+	// RateLimit answers every login with 429 Too Many Requests.
+	RateLimit Scenario = "rate-limit"
+	// China only accepts requests made against the China region endpoints.
+	China Scenario = "china"
+)
+
+// Scenarios lists every supported scenario.
+var Scenarios = []Scenario{PasswordOnly, EmailCode, TOTP, WrongCode, RateLimit, China}
+
+const (
+	DefaultAccount    = "user@example.com"
+	DefaultPassword   = "password"
+	DefaultCode       = "123456"
+	DefaultUID        = 1234567890
+	DefaultSerial     = "01P00A000000001"
+	DefaultAccessCode = "12345678"
+	tfaKey            = "mock-tfa-key"
+	expiresIn         = 7776000
+	refreshExpiresIn  = 7776000
+	retryAfter        = 60
+)
+
+// Config describes the account the mock server accepts and the scenario it plays.
+type Config struct {
+	Scenario Scenario
+	Account  string
+	Password string
+	// Code is the accepted email code or one-time password.
+	Code string
+	// RejectCodes is how many codes the WrongCode scenario rejects before accepting one.
+	RejectCodes int
+	// ExpiresIn overrides the lifetime of issued access tokens in seconds.
+	ExpiresIn int
+	// CodeCooldown is the minimum time between two email or SMS codes; earlier requests get 429.
+	CodeCooldown time.Duration
+	// OmitTokens answers successful logins without any tokens, like a broken upstream response.
+	OmitTokens bool
+}
+
+// Stats counts the calls the mock server has handled.
+type Stats struct {
+	Logins       int
+	EmailsSent   int
+	SMSSent      int
+	CodeAttempts int
+	Refreshes    int
+	Profiles     int
+	Devices      int
+}
+
+// Server emulates the Bambu cloud login, email and SMS code, TFA, refresh, profile and device list endpoints.
+type Server struct {
+	cfg Config
+
+	mu            sync.Mutex
+	stats         Stats
+	rejected      int
+	codeSentAt    time.Time
+	issued        map[string]bool
+	refreshTokens map[string]bool
+	serial        int
+}
+
+// New returns a mock server for the given config, filling in defaults for empty fields.
+func New(cfg Config) *Server {
+	if cfg.Scenario == "" {
+		cfg.Scenario = PasswordOnly
+	}
+	if cfg.Account == "" {
+		cfg.Account = DefaultAccount
+	}
+	if cfg.Password == "" {
+		cfg.Password = DefaultPassword
+	}
+	if cfg.Code == "" {
+		cfg.Code = DefaultCode
+	}
+	if cfg.Scenario == WrongCode && cfg.RejectCodes == 0 {
+		cfg.RejectCodes = 1
+	}
+	if cfg.ExpiresIn == 0 {
+		cfg.ExpiresIn = expiresIn
+	}
+
+	return &Server{
+		cfg:           cfg,
+		issued:        map[string]bool{},
+		refreshTokens: map[string]bool{},
+	}
+}
+
+// ParseScenario validates a scenario name.
+func ParseScenario(name string) (Scenario, error) {
+	for _, scenario := range Scenarios {
+		if string(scenario) == name {
+			return scenario, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown scenario: %v", name)
+}
+
+// Start serves the mock on a random local port. Point the tool at it with consts.SetBaseURL(server.URL).
+func Start(cfg Config) (*Server, *httptest.Server) {
+	s := New(cfg)
+	return s, httptest.NewServer(s)
+}
+
+// Listen serves the mock on the given address, e.g. "127.0.0.1:8080".
+func Listen(cfg Config, addr string) (*Server, *httptest.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %v: %v", addr, err)
+	}
+
+	s := New(cfg)
+	ts := httptest.NewUnstartedServer(s)
+	ts.Listener.Close()
+	ts.Listener = listener
+	ts.Start()
+
+	return s, ts, nil
+}
+
+// Stats returns a snapshot of the handled calls.
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+// ServeHTTP routes a request to the emulated endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, china := strings.CutPrefix(r.URL.Path, consts.ChinaPathPrefix)
+
+	if s.cfg.Scenario == China && !china {
+		writeError(w, http.StatusBadRequest, 5, "Account is registered in the China region")
+		return
+	}
+
+	region := "global"
+	if china {
+		region = "china"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && path == consts.LoginURL.Path():
+		s.handleLogin(w, r, region)
+	case r.Method == http.MethodPost && path == consts.EmailCodeURL.Path():
+		s.handleSendEmailCode(w, r)
+	case r.Method == http.MethodPost && path == consts.SMSCodeURL.Path():
+		s.handleSendSMSCode(w, r)
+	case r.Method == http.MethodPost && path == consts.TwoFactorURL.Path():
+		s.handleTwoFactor(w, r, region)
+	case r.Method == http.MethodPost && path == consts.RefreshTokenURL.Path():
+		s.handleRefresh(w, r, region)
+	case r.Method == http.MethodGet && path == consts.ProfileURL.Path():
+		s.handleProfile(w, r, region)
+	case r.Method == http.MethodGet && path == consts.BindURL.Path():
+		s.handleDevices(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// loginRequest covers both the password and the email code login bodies.
+type loginRequest struct {
+	Account  string `json:"account"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request, region string) {
+	s.stats.Logins++
+
+	if s.cfg.Scenario == RateLimit {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, http.StatusTooManyRequests, 429, "Too many requests, please try again later")
+		return
+	}
+
+	var body loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, 1, "Invalid request body")
+		return
+	}
+
+	if body.Account != s.cfg.Account {
+		writeError(w, http.StatusBadRequest, 1, "Incorrect account or password")
+		return
+	}
+
+	// Second step of the email code flow
+	if body.Code != "" {
+		if !s.acceptCode(body.Code) {
+			writeError(w, http.StatusBadRequest, 2, "Incorrect verification code")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.loginTokens(region))
+		return
+	}
+
+	if body.Password != s.cfg.Password {
+		writeError(w, http.StatusBadRequest, 1, "Incorrect account or password")
+		return
+	}
+
+	switch s.cfg.Scenario {
+	case EmailCode, WrongCode:
+		writeJSON(w, http.StatusOK, types.LoginResponse{LoginType: "verifyCode"})
+	case TOTP:
+		writeJSON(w, http.StatusOK, types.LoginResponse{LoginType: "tfa", TfaKey: tfaKey})
+	default:
+		writeJSON(w, http.StatusOK, s.loginTokens(region))
+	}
+}
+
+func (s *Server) handleSendEmailCode(w http.ResponseWriter, r *http.Request) {
+	var body types.RequestEmailCodePayload
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email != s.cfg.Account {
+		writeError(w, http.StatusBadRequest, 1, "Invalid email")
+		return
+	}
+	if !s.codeCooldownOver(w) {
+		return
+	}
+
+	s.stats.EmailsSent++
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSendSMSCode(w http.ResponseWriter, r *http.Request) {
+	var body types.RequestSMSCodePayload
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Phone != s.cfg.Account {
+		writeError(w, http.StatusBadRequest, 1, "Invalid phone number")
+		return
+	}
+	if !s.codeCooldownOver(w) {
+		return
+	}
+
+	s.stats.SMSSent++
+	w.WriteHeader(http.StatusOK)
+}
+
+// codeCooldownOver records a code being sent, or answers 429 with Retry-After while the cooldown lasts.
+func (s *Server) codeCooldownOver(w http.ResponseWriter) bool {
+	if wait := s.cfg.CodeCooldown - time.Since(s.codeSentAt); !s.codeSentAt.IsZero() && wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)+1))
+		writeError(w, http.StatusTooManyRequests, 429, "Code requested too often")
+		return false
+	}
+
+	s.codeSentAt = time.Now()
+	return true
+}
+
+func (s *Server) handleTwoFactor(w http.ResponseWriter, r *http.Request, region string) {
+	var body types.TwoFactorPayload
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.TFAKey != tfaKey {
+		writeError(w, http.StatusBadRequest, 1, "Invalid tfa key")
+		return
+	}
+
+	if !s.acceptCode(body.TFACode) {
+		writeError(w, http.StatusBadRequest, 2, "Incorrect verification code")
+		return
+	}
+
+	if s.cfg.OmitTokens {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	tokens := s.issueTokens(region)
+	for name, value := range map[string]string{
+		"token":            tokens.AccessToken,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresIn":        strconv.Itoa(tokens.ExpiresIn),
+		"refreshExpiresIn": strconv.Itoa(tokens.RefreshExpiresIn),
+	} {
+		http.SetCookie(w, &http.Cookie{Name: name, Value: value, Path: "/", HttpOnly: true})
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request, region string) {
+	var body types.RefreshTokenPayload
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !s.refreshTokens[body.RefreshToken] {
+		writeError(w, http.StatusUnauthorized, 401, "Invalid refresh token")
+		return
+	}
+
+	s.stats.Refreshes++
+	delete(s.refreshTokens, body.RefreshToken)
+	writeJSON(w, http.StatusOK, s.issueTokens(region))
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request, region string) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, 401, "Unauthorized")
+		return
+	}
+
+	s.stats.Profiles++
+	writeJSON(w, http.StatusOK, map[string]any{
+		"uid":     DefaultUID,
+		"account": s.cfg.Account,
+		"name":    "Mock User",
+		"region":  region,
+	})
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, 401, "Unauthorized")
+		return
+	}
+
+	s.stats.Devices++
+	writeJSON(w, http.StatusOK, types.DevicesResponse{
+		Devices: []types.Device{{
+			Serial:      DefaultSerial,
+			Name:        "Mock Printer",
+			Online:      true,
+			Model:       "P1S",
+			AccessCode:  DefaultAccessCode,
+			PrintStatus: "IDLE",
+		}},
+	})
+}
+
+// authorized reports whether the request carries an access token the server issued.
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "token ")
+	return ok && s.issued[token]
+}
+
+// acceptCode checks a submitted code, rejecting the first RejectCodes attempts in the WrongCode scenario.
+func (s *Server) acceptCode(code string) bool {
+	s.stats.CodeAttempts++
+
+	if s.rejected < s.cfg.RejectCodes {
+		s.rejected++
+		return false
+	}
+
+	return code == s.cfg.Code
+}
+
+// loginTokens answers a successful login, without tokens when the config omits them.
+func (s *Server) loginTokens(region string) types.LoginResponse {
+	if s.cfg.OmitTokens {
+		return types.LoginResponse{}
+	}
+
+	return s.issueTokens(region)
+}
+
+// issueTokens mints a new JWT-shaped access token and an opaque refresh token.
+func (s *Server) issueTokens(region string) types.LoginResponse {
+	s.serial++
+	now := time.Now()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"uid":    DefaultUID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Duration(s.cfg.ExpiresIn) * time.Second).Unix(),
+		"region": region,
+		"jti":    s.serial,
+	})
+	accessToken := header + "." + base64.RawURLEncoding.EncodeToString(claims) + ".mock-signature"
+	refreshToken := fmt.Sprintf("mock-refresh-token-%d", s.serial)
+
+	s.issued[accessToken] = true
+	s.refreshTokens[refreshToken] = true
+
+	return types.LoginResponse{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        s.cfg.ExpiresIn,
+		RefreshExpiresIn: refreshExpiresIn,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, map[string]any{
+		"code":  code,
+		"error": message,
+	})
+}
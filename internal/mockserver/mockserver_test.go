@@ -0,0 +1,615 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/auth"
+	"github.com/ondrovic/bambulab-authenticator/internal/consts"
+	"github.com/ondrovic/bambulab-authenticator/internal/cookies"
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withStdin feeds input to code reading from os.Stdin.
+func withStdin(t *testing.T, input string) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = writer.WriteString(input)
+	require.NoError(t, err)
+	writer.Close()
+
+	original := os.Stdin
+	os.Stdin = reader
+	t.Cleanup(func() {
+		os.Stdin = original
+		reader.Close()
+	})
+}
+
+func TestLoginScenarios(t *testing.T) {
+	tests := []struct {
+		name         string
+		scenario     Scenario
+		method       string
+		region       string
+		stdin        string
+		expectErr    bool
+		expectEmails int
+	}{
+		{
+			name:     "Password only",
+			scenario: PasswordOnly,
+			region:   "us",
+		},
+		{
+			name:         "Email code",
+			scenario:     EmailCode,
+			region:       "us",
+			stdin:        DefaultCode + "\n",
+			expectEmails: 1,
+		},
+		{
+			name:         "Email code method without password",
+			scenario:     PasswordOnly,
+			method:       auth.MethodEmailCode,
+			region:       "us",
+			stdin:        DefaultCode + "\n",
+			expectEmails: 1,
+		},
+		{
+			name:     "TOTP",
+			scenario: TOTP,
+			region:   "us",
+			stdin:    DefaultCode + "\n",
+		},
+		{
+			name:      "Rate limit",
+			scenario:  RateLimit,
+			region:    "us",
+			expectErr: true,
+		},
+		{
+			name:     "China region",
+			scenario: China,
+			region:   "china",
+		},
+		{
+			name:      "China account on global endpoint",
+			scenario:  China,
+			region:    "us",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, ts := Start(Config{Scenario: tt.scenario})
+			defer ts.Close()
+
+			consts.SetBaseURL(ts.URL)
+			defer consts.SetBaseURL("")
+			require.NoError(t, httpclient.InitClient(""))
+
+			if tt.stdin != "" {
+				withStdin(t, tt.stdin)
+			}
+
+			// The email code method must not need the password
+			password := DefaultPassword
+			if tt.method == auth.MethodEmailCode {
+				password = ""
+			}
+
+			outputPath := t.TempDir()
+			err := auth.Login(&types.CliFlags{
+				Method:       tt.method,
+				UserAccount:  DefaultAccount,
+				UserPassword: password,
+				UserRegion:   tt.region,
+				OutputPath:   outputPath,
+			})
+
+			assert.Equal(t, tt.expectEmails, server.Stats().EmailsSent, "Unexpected number of emails sent")
+
+			if tt.expectErr {
+				assert.Error(t, err)
+				_, statErr := os.Stat(utils.AuthFilePath(outputPath))
+				assert.True(t, os.IsNotExist(statErr), "No auth file should be written")
+				return
+			}
+
+			require.NoError(t, err)
+			saved, err := utils.LoadLoginResponseFromFile(outputPath)
+			require.NoError(t, err)
+			assert.NotEmpty(t, saved.AccessToken)
+			assert.NotEmpty(t, saved.RefreshToken)
+			assert.Equal(t, expiresIn, saved.ExpiresIn)
+		})
+	}
+}
+
+func TestPhoneAccountGetsSMSCode(t *testing.T) {
+	server, ts := Start(Config{Scenario: China, Account: "13800138000"})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+	require.NoError(t, httpclient.InitClient(""))
+
+	outputPath := t.TempDir()
+	require.NoError(t, auth.Login(&types.CliFlags{
+		Method:      auth.MethodEmailCode,
+		UserAccount: "+86 138 0013 8000",
+		UserRegion:  "china",
+		OutputPath:  outputPath,
+		Code:        DefaultCode,
+	}))
+
+	stats := server.Stats()
+	assert.Equal(t, 1, stats.SMSSent)
+	assert.Equal(t, 0, stats.EmailsSent)
+
+	saved, err := utils.LoadLoginResponseFromFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", saved.Account, "The normalized number should be saved")
+}
+
+func TestCodeRetriesAndResend(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            Config
+		opts           types.CliFlags
+		stdin          string
+		expectErr      bool
+		expectAttempts int
+		expectEmails   int
+	}{
+		{
+			name:           "Re-prompts after a wrong code",
+			cfg:            Config{Scenario: WrongCode, RejectCodes: 1},
+			opts:           types.CliFlags{CodeAttempts: 3},
+			stdin:          "000000\n" + DefaultCode + "\n",
+			expectAttempts: 2,
+			expectEmails:   1,
+		},
+		{
+			name:           "Gives up when the attempts are used",
+			cfg:            Config{Scenario: WrongCode, RejectCodes: 5},
+			opts:           types.CliFlags{CodeAttempts: 2},
+			stdin:          "000000\n111111\n" + DefaultCode + "\n",
+			expectErr:      true,
+			expectAttempts: 2,
+			expectEmails:   1,
+		},
+		{
+			name:           "Code flag is not retried",
+			cfg:            Config{Scenario: WrongCode, RejectCodes: 1},
+			opts:           types.CliFlags{CodeAttempts: 3, Code: DefaultCode},
+			expectErr:      true,
+			expectAttempts: 1,
+			expectEmails:   1,
+		},
+		{
+			name:           "Resend sends a new code",
+			cfg:            Config{Scenario: EmailCode},
+			opts:           types.CliFlags{CodeAttempts: 3},
+			stdin:          "r\n" + DefaultCode + "\n",
+			expectAttempts: 1,
+			expectEmails:   2,
+		},
+		{
+			name:           "Resend refused by the server cooldown",
+			cfg:            Config{Scenario: EmailCode, CodeCooldown: time.Hour},
+			opts:           types.CliFlags{CodeAttempts: 3},
+			stdin:          "r\n" + DefaultCode + "\n",
+			expectAttempts: 1,
+			expectEmails:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, ts := Start(tt.cfg)
+			defer ts.Close()
+
+			consts.SetBaseURL(ts.URL)
+			defer consts.SetBaseURL("")
+			require.NoError(t, httpclient.InitClient(""))
+
+			if tt.stdin != "" {
+				withStdin(t, tt.stdin)
+			}
+
+			opts := tt.opts
+			opts.UserAccount = DefaultAccount
+			opts.UserPassword = DefaultPassword
+			opts.UserRegion = "us"
+			opts.OutputPath = t.TempDir()
+			err := auth.Login(&opts)
+
+			stats := server.Stats()
+			assert.Equal(t, tt.expectAttempts, stats.CodeAttempts, "Unexpected number of code attempts")
+			assert.Equal(t, tt.expectEmails, stats.EmailsSent, "Unexpected number of emails sent")
+
+			if tt.expectErr {
+				require.Error(t, err)
+				assert.Equal(t, types.ErrCodeLoginFailed, types.ErrorCode(err))
+				assert.NoFileExists(t, utils.AuthFilePath(opts.OutputPath))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWrongCodeRejectsFirstAttempt(t *testing.T) {
+	server := New(Config{Scenario: WrongCode})
+
+	assert.False(t, server.acceptCode(DefaultCode), "First code should be rejected")
+	assert.True(t, server.acceptCode(DefaultCode), "Second code should be accepted")
+	assert.False(t, server.acceptCode("000000"), "Incorrect code should be rejected")
+	assert.Equal(t, 3, server.Stats().CodeAttempts)
+}
+
+func TestRefreshAndProfile(t *testing.T) {
+	server, ts := Start(Config{})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+
+	outputPath := t.TempDir()
+	opts := &types.CliFlags{
+		UserAccount:  DefaultAccount,
+		UserPassword: DefaultPassword,
+		UserRegion:   "us",
+		OutputPath:   outputPath,
+	}
+	require.NoError(t, httpclient.InitClient(""))
+	require.NoError(t, auth.Login(opts))
+
+	// Invalidate the saved access token so the profile call has to refresh
+	saved, err := utils.LoadLoginResponseFromFile(outputPath)
+	require.NoError(t, err)
+	staleToken := saved.AccessToken
+	saved.AccessToken = "revoked"
+	require.NoError(t, utils.SaveLoginResponseToFile(*saved, outputPath))
+
+	_, err = auth.LoadSession(opts)
+	require.NoError(t, err)
+
+	profileURL, err := consts.RegionalURL(consts.ProfileURL, opts.UserRegion)
+	require.NoError(t, err)
+	_, err = httpclient.Request("GET", string(profileURL), nil)
+	require.NoError(t, err)
+
+	stats := server.Stats()
+	assert.Equal(t, 1, stats.Refreshes, "Expired token should be refreshed once")
+	assert.Equal(t, 1, stats.Profiles, "Profile should be served after the refresh")
+
+	refreshed, err := utils.LoadLoginResponseFromFile(outputPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, staleToken, refreshed.AccessToken, "Refreshed token should be persisted")
+}
+
+func TestFetchDevices(t *testing.T) {
+	server, ts := Start(Config{})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+
+	outputPath := t.TempDir()
+	opts := &types.CliFlags{
+		UserAccount:  DefaultAccount,
+		UserPassword: DefaultPassword,
+		UserRegion:   "us",
+		OutputPath:   outputPath,
+	}
+	require.NoError(t, httpclient.InitClient(""))
+	require.NoError(t, auth.Login(opts))
+
+	_, err := auth.LoadEphemeralSession(opts)
+	require.NoError(t, err)
+
+	devices, err := auth.FetchDevices(opts.UserRegion)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, DefaultSerial, devices[0].Serial)
+	assert.Equal(t, DefaultAccessCode, devices[0].AccessCode)
+	assert.Equal(t, 1, server.Stats().Devices)
+}
+
+func TestImportCookies(t *testing.T) {
+	server, ts := Start(Config{})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+
+	loginPath := t.TempDir()
+	require.NoError(t, httpclient.InitClient(""))
+	require.NoError(t, auth.Login(&types.CliFlags{
+		UserAccount:  DefaultAccount,
+		UserPassword: DefaultPassword,
+		UserRegion:   "us",
+		OutputPath:   loginPath,
+	}))
+	saved, err := utils.LoadLoginResponseFromFile(loginPath)
+	require.NoError(t, err)
+
+	exported := fmt.Sprintf("# Netscape HTTP Cookie File\n"+
+		"#HttpOnly_.bambulab.com\tTRUE\t/\tTRUE\t%d\ttoken\t%s\n"+
+		".bambulab.com\tTRUE\t/\tTRUE\t0\trefreshToken\t%s\n"+
+		".example.com\tTRUE\t/\tTRUE\t0\ttoken\tunrelated\n",
+		time.Now().Add(time.Hour).Unix(), saved.AccessToken, saved.RefreshToken)
+	browserCookies, err := cookies.Parse([]byte(exported))
+	require.NoError(t, err)
+
+	importPath := t.TempDir()
+	opts := &types.CliFlags{OutputPath: importPath}
+	imported, err := auth.ImportCookies(browserCookies, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, saved.AccessToken, imported.AccessToken)
+	assert.Equal(t, DefaultAccount, imported.Account)
+	assert.Equal(t, "global", imported.Region)
+	assert.Equal(t, 1, server.Stats().Profiles)
+
+	reloaded, err := utils.LoadLoginResponseFromFile(importPath)
+	require.NoError(t, err)
+	assert.Equal(t, imported.AccessToken, reloaded.AccessToken)
+	assert.NotZero(t, reloaded.ExpiresAt)
+
+	// Cookies the server does not know about are rejected and nothing is saved
+	rejectedPath := t.TempDir()
+	_, err = auth.ImportCookies([]*http.Cookie{{Domain: ".bambulab.com", Name: "token", Value: "forged"}}, &types.CliFlags{OutputPath: rejectedPath})
+	require.Error(t, err)
+	assert.Equal(t, types.ErrCodeSessionInvalid, types.ErrorCode(err))
+	assert.NoFileExists(t, utils.AuthFilePath(rejectedPath))
+}
+
+func TestParseScenario(t *testing.T) {
+	for _, scenario := range Scenarios {
+		got, err := ParseScenario(string(scenario))
+		assert.NoError(t, err)
+		assert.Equal(t, scenario, got)
+	}
+
+	_, err := ParseScenario("unknown")
+	assert.Error(t, err)
+}
+
+func TestEphemeralSessionEnvironment(t *testing.T) {
+	server, ts := Start(Config{})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+
+	outputPath := t.TempDir()
+	require.NoError(t, httpclient.InitClient(""))
+	require.NoError(t, auth.Login(&types.CliFlags{
+		UserAccount:  DefaultAccount,
+		UserPassword: DefaultPassword,
+		UserRegion:   "us",
+		OutputPath:   outputPath,
+	}))
+
+	// Revoke the saved access token so the session has to refresh it
+	saved, err := utils.LoadLoginResponseFromFile(outputPath)
+	require.NoError(t, err)
+	saved.AccessToken = "revoked"
+	require.NoError(t, utils.SaveLoginResponseToFile(*saved, outputPath))
+	before, err := os.ReadFile(utils.AuthFilePath(outputPath))
+	require.NoError(t, err)
+
+	// The region comes from the auth file
+	opts := &types.CliFlags{OutputPath: outputPath}
+	_, err = auth.LoadEphemeralSession(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "us", opts.UserRegion)
+
+	env, err := auth.SessionEnvironment(opts.UserRegion)
+	require.NoError(t, err)
+
+	values := map[string]string{}
+	for _, variable := range env {
+		values[variable.Name] = variable.Value
+	}
+	assert.NotEqual(t, "revoked", values[auth.EnvToken], "Token should be refreshed")
+	assert.Equal(t, values[auth.EnvToken], values[auth.EnvMQTTPassword])
+	assert.Equal(t, "u_1234567890", values[auth.EnvMQTTUsername])
+	assert.Equal(t, consts.MQTTHost, values[auth.EnvMQTTHost])
+	assert.Equal(t, 1, server.Stats().Refreshes)
+
+	after, err := os.ReadFile(utils.AuthFilePath(outputPath))
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "Ephemeral sessions must not write the auth file")
+}
+
+func TestLimitsPersistAcrossRuns(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          Config
+		opts         types.CliFlags
+		runs         int
+		expectLogins int
+		expectEmails int
+	}{
+		{
+			name:         "Server cooldown",
+			cfg:          Config{Scenario: RateLimit},
+			opts:         types.CliFlags{UserPassword: DefaultPassword},
+			runs:         3,
+			expectLogins: 1,
+		},
+		{
+			name:         "Server cooldown forced",
+			cfg:          Config{Scenario: RateLimit},
+			opts:         types.CliFlags{UserPassword: DefaultPassword, Force: true},
+			runs:         3,
+			expectLogins: 3,
+		},
+		{
+			name:         "Repeated wrong passwords",
+			cfg:          Config{Scenario: PasswordOnly},
+			opts:         types.CliFlags{UserPassword: "wrong"},
+			runs:         7,
+			expectLogins: 5,
+		},
+		{
+			name:         "Code sent recently",
+			cfg:          Config{Scenario: EmailCode},
+			opts:         types.CliFlags{Method: auth.MethodEmailCode, Code: DefaultCode, ResendCooldown: time.Minute},
+			runs:         2,
+			expectLogins: 1,
+			expectEmails: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, ts := Start(tt.cfg)
+			defer ts.Close()
+
+			consts.SetBaseURL(ts.URL)
+			defer consts.SetBaseURL("")
+			require.NoError(t, httpclient.InitClient(""))
+
+			stateDir := t.TempDir()
+			var err error
+			for range tt.runs {
+				opts := tt.opts
+				opts.UserAccount = DefaultAccount
+				opts.UserRegion = "us"
+				opts.OutputPath = t.TempDir()
+				opts.StateDir = stateDir
+				err = auth.Login(&opts)
+			}
+
+			stats := server.Stats()
+			assert.Equal(t, tt.expectLogins, stats.Logins, "Unexpected number of logins")
+			assert.Equal(t, tt.expectEmails, stats.EmailsSent, "Unexpected number of emails sent")
+
+			require.Error(t, err)
+			if !tt.opts.Force {
+				assert.Equal(t, types.ErrCodeRateLimited, types.ErrorCode(err))
+			}
+		})
+	}
+}
+
+func TestSaveCookies(t *testing.T) {
+	_, ts := Start(Config{Scenario: TOTP})
+	defer ts.Close()
+
+	consts.SetBaseURL(ts.URL)
+	defer consts.SetBaseURL("")
+	require.NoError(t, httpclient.InitClient(""))
+
+	outputPath := t.TempDir()
+	require.NoError(t, auth.Login(&types.CliFlags{
+		UserAccount:  DefaultAccount,
+		UserPassword: DefaultPassword,
+		UserRegion:   "us",
+		OutputPath:   outputPath,
+		Code:         DefaultCode,
+		SaveCookies:  true,
+	}))
+
+	info, err := os.Stat(utils.CookieFilePath(outputPath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A new session starts with the saved cookies
+	require.NoError(t, httpclient.InitClient(""))
+	_, err = auth.LoadSession(&types.CliFlags{OutputPath: outputPath})
+	require.NoError(t, err)
+
+	u, err := url.Parse(ts.URL + "/api/sign-in/tfa")
+	require.NoError(t, err)
+	names := []string{}
+	for _, cookie := range httpclient.Jar.Cookies(u) {
+		names = append(names, cookie.Name)
+	}
+	assert.Contains(t, names, "refreshToken")
+}
+
+func TestResponsesWithoutTokensAreNotSaved(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario Scenario
+	}{
+		{name: "Password login", scenario: PasswordOnly},
+		{name: "Email code", scenario: EmailCode},
+		{name: "TFA without cookies", scenario: TOTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := t.TempDir()
+			stateDir := t.TempDir()
+			helperLog := filepath.Join(t.TempDir(), "helper.log")
+			login := func(cfg Config) error {
+				_, ts := Start(cfg)
+				defer ts.Close()
+
+				consts.SetBaseURL(ts.URL)
+				defer consts.SetBaseURL("")
+				require.NoError(t, httpclient.InitClient(""))
+
+				return auth.Login(&types.CliFlags{
+					UserAccount:  DefaultAccount,
+					UserPassword: DefaultPassword,
+					UserRegion:   "us",
+					OutputPath:   outputPath,
+					Code:         DefaultCode,
+					StateDir:     stateDir,
+					// Records the action of every call
+					CredentialHelper: fmt.Sprintf(`f() { cat >/dev/null; echo "$1" >> %q; }; f`, helperLog),
+				})
+			}
+
+			// A working session is saved first
+			require.NoError(t, login(Config{Scenario: tt.scenario}))
+			before, err := os.ReadFile(utils.AuthFilePath(outputPath))
+			require.NoError(t, err)
+
+			err = login(Config{Scenario: tt.scenario, OmitTokens: true})
+			require.Error(t, err)
+			assert.Equal(t, types.ErrCodeLoginFailed, types.ErrorCode(err))
+			assert.Contains(t, err.Error(), "access token missing")
+			assert.Contains(t, err.Error(), "the existing auth file was kept")
+
+			after, err := os.ReadFile(utils.AuthFilePath(outputPath))
+			require.NoError(t, err)
+			assert.Equal(t, string(before), string(after), "The working auth file must not be overwritten")
+
+			actions, err := os.ReadFile(helperLog)
+			require.NoError(t, err)
+			assert.Equal(t, "store\n", string(actions), "A broken response must not erase the saved password")
+
+			data, err := os.ReadFile(filepath.Join(stateDir, auth.LimitsFileName))
+			if !errors.Is(err, os.ErrNotExist) {
+				require.NoError(t, err)
+				var limits map[string]struct{ FailedAttempts int }
+				require.NoError(t, json.Unmarshal(data, &limits))
+				for _, account := range limits {
+					assert.Zero(t, account.FailedAttempts, "A broken response must not count as a failed login")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is a monitoring plugin state, its value is the plugin exit code.
+type Status int
+
+// Monitoring plugin states, see https://www.monitoring-plugins.org/doc/guidelines.html.
+const (
+	OK       Status = 0
+	Warning  Status = 1
+	Critical Status = 2
+	Unknown  Status = 3
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarshalText reports the status by name in json output.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// severity orders states for the overall result: CRITICAL outranks UNKNOWN, which outranks WARNING.
+func (s Status) severity() int {
+	switch s {
+	case Critical:
+		return 3
+	case Unknown:
+		return 2
+	case Warning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Thresholds alert when less than the given time remains.
+type Thresholds struct {
+	Warning  time.Duration
+	Critical time.Duration
+}
+
+// Check is the outcome of one probe.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Perfdata is one performance data value. Empty Warn and Crit are left out of the output.
+type Perfdata struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+	Warn  string  `json:"warn,omitempty"`
+	Crit  string  `json:"crit,omitempty"`
+	Min   string  `json:"min,omitempty"`
+}
+
+func (p Perfdata) String() string {
+	value := strconv.FormatFloat(p.Value, 'f', -1, 64) + p.Unit
+	return strings.TrimRight(fmt.Sprintf("%s=%s;%s;%s;%s", p.Label, value, p.Warn, p.Crit, p.Min), ";")
+}
+
+// Report collects the checks of one run.
+type Report struct {
+	Checks   []Check    `json:"checks"`
+	Perfdata []Perfdata `json:"perfdata"`
+}
+
+// Add appends a check and its performance data.
+func (r *Report) Add(check Check, perfdata ...Perfdata) {
+	r.Checks = append(r.Checks, check)
+	r.Perfdata = append(r.Perfdata, perfdata...)
+}
+
+// Status returns the most severe state of the checks, OK when there are none.
+func (r Report) Status() Status {
+	status := OK
+	for _, check := range r.Checks {
+		if check.Status.severity() > status.severity() {
+			status = check.Status
+		}
+	}
+
+	return status
+}
+
+// Nagios renders the report as a plugin output line: service name, state, messages and perfdata.
+func (r Report) Nagios(service string) string {
+	messages := make([]string, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		messages = append(messages, check.Message)
+	}
+
+	line := fmt.Sprintf("%s %s - %s", service, r.Status(), strings.Join(messages, ", "))
+	if len(r.Perfdata) == 0 {
+		return line
+	}
+
+	perfdata := make([]string, 0, len(r.Perfdata))
+	for _, p := range r.Perfdata {
+		perfdata = append(perfdata, p.String())
+	}
+
+	return line + " | " + strings.Join(perfdata, " ")
+}
+
+// ExpiryCheck checks the time left before expiresAt (unix seconds) against the thresholds and returns
+// the remaining seconds as perfdata named label. A zero expiresAt is UNKNOWN.
+func ExpiryCheck(name string, label string, expiresAt int64, now time.Time, thresholds Thresholds) (Check, []Perfdata) {
+	check := Check{Name: name}
+	if expiresAt == 0 {
+		check.Status = Unknown
+		check.Message = name + " expiry unknown"
+		return check, nil
+	}
+
+	remaining := time.Unix(expiresAt, 0).Sub(now)
+	switch {
+	case remaining <= 0:
+		check.Status = Critical
+		check.Message = fmt.Sprintf("%s expired %s ago", name, FormatDuration(-remaining))
+	case remaining < thresholds.Critical:
+		check.Status = Critical
+		check.Message = fmt.Sprintf("%s expires in %s", name, FormatDuration(remaining))
+	case remaining < thresholds.Warning:
+		check.Status = Warning
+		check.Message = fmt.Sprintf("%s expires in %s", name, FormatDuration(remaining))
+	default:
+		check.Status = OK
+		check.Message = fmt.Sprintf("%s expires in %s", name, FormatDuration(remaining))
+	}
+
+	// "N:" alerts when the value drops below N
+	perfdata := Perfdata{
+		Label: label,
+		Value: remaining.Truncate(time.Second).Seconds(),
+		Unit:  "s",
+		Warn:  fmt.Sprintf("%.0f:", thresholds.Warning.Seconds()),
+		Crit:  fmt.Sprintf("%.0f:", thresholds.Critical.Seconds()),
+		Min:   "0",
+	}
+
+	return check, []Perfdata{perfdata}
+}
+
+// FormatDuration renders a duration with its two largest units, e.g. 89d 23h, 5h 3m or 42s.
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// Metric is one Prometheus sample.
+type Metric struct {
+	Name   string
+	Help   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Prometheus renders the metrics in the text exposition format read by the node_exporter textfile
+// collector. Samples of the same name are grouped under one HELP and TYPE header.
+func Prometheus(metrics []Metric) string {
+	var b strings.Builder
+
+	written := map[string]bool{}
+	for i, metric := range metrics {
+		if written[metric.Name] {
+			continue
+		}
+		written[metric.Name] = true
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", metric.Name, metric.Help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", metric.Name)
+		for _, sample := range metrics[i:] {
+			if sample.Name == metric.Name {
+				fmt.Fprintf(&b, "%s%s %s\n", sample.Name, formatLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'f', -1, 64))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, escapeLabel(labels[name])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel leaves quoting to %q, which escapes backslashes, quotes and newlines as Prometheus expects,
+// but also other control characters it does not know about, so those are dropped first.
+func escapeLabel(value string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < ' ' && r != '\n') || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+}
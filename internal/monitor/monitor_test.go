@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryCheck(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	thresholds := Thresholds{Warning: 7 * 24 * time.Hour, Critical: 24 * time.Hour}
+
+	tests := []struct {
+		name      string
+		expiresAt int64
+		status    Status
+		message   string
+	}{
+		{"unknown", 0, Unknown, "token expiry unknown"},
+		{"ok", now.Add(30 * 24 * time.Hour).Unix(), OK, "token expires in 30d 0h"},
+		{"warning", now.Add(3 * 24 * time.Hour).Unix(), Warning, "token expires in 3d 0h"},
+		{"critical", now.Add(5 * time.Hour).Unix(), Critical, "token expires in 5h 0m"},
+		{"expired", now.Add(-90 * time.Second).Unix(), Critical, "token expired 1m 30s ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, perfdata := ExpiryCheck("token", "token_remaining", tt.expiresAt, now, thresholds)
+			assert.Equal(t, tt.status, check.Status)
+			assert.Equal(t, tt.message, check.Message)
+			if tt.expiresAt == 0 {
+				assert.Empty(t, perfdata)
+			} else {
+				assert.Len(t, perfdata, 1)
+			}
+		})
+	}
+}
+
+func TestExpiryCheckPerfdata(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	_, perfdata := ExpiryCheck("token", "token_remaining", now.Add(time.Hour).Unix(), now, Thresholds{Warning: 2 * time.Hour, Critical: time.Hour / 2})
+
+	assert.Equal(t, "token_remaining=3600s;7200:;1800:;0", perfdata[0].String())
+}
+
+func TestReportStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []Status
+		want     Status
+	}{
+		{"empty", nil, OK},
+		{"all ok", []Status{OK, OK}, OK},
+		{"warning", []Status{OK, Warning}, Warning},
+		{"unknown outranks warning", []Status{Warning, Unknown}, Unknown},
+		{"critical outranks unknown", []Status{Unknown, Critical, Warning}, Critical},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report Report
+			for _, status := range tt.statuses {
+				report.Add(Check{Status: status})
+			}
+			assert.Equal(t, tt.want, report.Status())
+		})
+	}
+}
+
+func TestNagios(t *testing.T) {
+	var report Report
+	report.Add(Check{Status: OK, Message: "token expires in 30d 0h"}, Perfdata{Label: "token_remaining", Value: 2592000, Unit: "s", Min: "0"})
+	report.Add(Check{Status: Warning, Message: "refresh token expires in 3d 0h"})
+	report.Add(Check{Status: OK, Message: "profile endpoint OK"}, Perfdata{Label: "profile_time", Value: 0.123, Unit: "s"})
+
+	assert.Equal(t, "BAMBU WARNING - token expires in 30d 0h, refresh token expires in 3d 0h, profile endpoint OK | token_remaining=2592000s;;;0 profile_time=0.123s", report.Nagios("BAMBU"))
+
+	assert.Equal(t, "BAMBU OK - ", (&Report{}).Nagios("BAMBU"))
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{0, "0s"},
+		{42 * time.Second, "42s"},
+		{5*time.Minute + 3*time.Second + 400*time.Millisecond, "5m 3s"},
+		{5*time.Hour + 3*time.Minute, "5h 3m"},
+		{89*24*time.Hour + 23*time.Hour + 59*time.Minute, "89d 23h"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatDuration(tt.duration))
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	metrics := []Metric{
+		{Name: "bambu_token_remaining_seconds", Help: "Seconds left.", Labels: map[string]string{"token": "access", "account": "user@example.com"}, Value: 3600},
+		{Name: "bambu_check_status", Help: "Status.", Value: 1},
+		{Name: "bambu_token_remaining_seconds", Help: "Seconds left.", Labels: map[string]string{"token": "refresh", "account": "a\"b\\c\nd\te"}, Value: 7200.5},
+	}
+
+	want := `# HELP bambu_token_remaining_seconds Seconds left.
+# TYPE bambu_token_remaining_seconds gauge
+bambu_token_remaining_seconds{account="user@example.com",token="access"} 3600
+bambu_token_remaining_seconds{account="a\"b\\c\nde",token="refresh"} 7200.5
+# HELP bambu_check_status Status.
+# TYPE bambu_check_status gauge
+bambu_check_status 1
+`
+
+	assert.Equal(t, want, Prometheus(metrics))
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SchemaVersion is bumped whenever a field of Result is renamed, removed or changes meaning.
+// New optional fields may be added without bumping it.
+const SchemaVersion = 1
+
+// Result is the single JSON document a command prints in --output json mode.
+type Result struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Command       string `json:"command"`
+	Success       bool   `json:"success"`
+	// Message is the human readable result printed in text mode.
+	Message string `json:"message,omitempty"`
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+	// Files lists every file the command wrote.
+	Files            []string     `json:"files,omitempty"`
+	ExpiresAt        string       `json:"expiresAt,omitempty"`
+	RefreshExpiresAt string       `json:"refreshExpiresAt,omitempty"`
+	Data             any          `json:"data,omitempty"`
+	Error            *ResultError `json:"error,omitempty"`
+}
+
+// ResultError describes why a command failed.
+type ResultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidateFormat checks the --output value.
+func ValidateFormat(format string) error {
+	switch strings.ToLower(format) {
+	case FormatText, FormatJSON:
+		return nil
+	default:
+		return types.NewCodedError(types.ErrCodeInvalidArgument, fmt.Errorf("unknown output format: %v", format))
+	}
+}
+
+// NewResult returns a successful result for the given command.
+func NewResult(command string) Result {
+	return Result{
+		SchemaVersion: SchemaVersion,
+		Command:       command,
+		Success:       true,
+	}
+}
+
+// NewErrorResult returns a failed result carrying the error's code and message.
+func NewErrorResult(command string, err error) Result {
+	result := NewResult(command)
+	result.Success = false
+	result.Error = &ResultError{
+		Code:    types.ErrorCode(err),
+		Message: err.Error(),
+	}
+
+	return result
+}
+
+// WithTokens fills in the expiry timestamps of a saved login.
+func (r Result) WithTokens(tokens *types.LoginResponse) Result {
+	if tokens == nil {
+		return r
+	}
+	r.ExpiresAt = formatUnix(tokens.ExpiresAt)
+	r.RefreshExpiresAt = formatUnix(tokens.RefreshExpiresAt)
+
+	return r
+}
+
+func formatUnix(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+// Write prints the result in the given format. Text mode prints only the message.
+func Write(w io.Writer, format string, result Result) error {
+	if strings.ToLower(format) == FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	if result.Message != "" {
+		_, err := fmt.Fprintln(w, result.Message)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSON(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := NewResult("authenticate").WithTokens(&types.LoginResponse{ExpiresAt: expiresAt.Unix()})
+	result.Account = "user@example.com"
+	result.Region = "us"
+	result.Files = []string{"/tmp/auth.json"}
+	result.Message = "Auth data saved to /tmp/auth.json"
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatJSON, result))
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, float64(SchemaVersion), decoded["schemaVersion"])
+	assert.Equal(t, "authenticate", decoded["command"])
+	assert.Equal(t, true, decoded["success"])
+	assert.Equal(t, "2030-01-02T03:04:05Z", decoded["expiresAt"])
+	assert.NotContains(t, decoded, "refreshExpiresAt", "Unknown expiries should be omitted")
+	assert.NotContains(t, decoded, "error")
+}
+
+func TestWriteText(t *testing.T) {
+	result := NewResult("authenticate")
+	result.Message = "Auth data saved to /tmp/auth.json"
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatText, result))
+
+	assert.Equal(t, "Auth data saved to /tmp/auth.json\n", buf.String())
+}
+
+func TestNewErrorResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode string
+	}{
+		{
+			name:         "Coded error",
+			err:          types.NewCodedError(types.ErrCodeLoginFailed, errors.New("bad password")),
+			expectedCode: types.ErrCodeLoginFailed,
+		},
+		{
+			name:         "Plain error",
+			err:          errors.New("boom"),
+			expectedCode: types.ErrCodeUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewErrorResult("authenticate", tt.err)
+
+			assert.False(t, result.Success)
+			require.NotNil(t, result.Error)
+			assert.Equal(t, tt.expectedCode, result.Error.Code)
+			assert.Equal(t, tt.err.Error(), result.Error.Message)
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, ValidateFormat("text"))
+	assert.NoError(t, ValidateFormat("JSON"))
+	assert.Error(t, ValidateFormat("yaml"))
+}
@@ -0,0 +1,90 @@
+package shellenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// Supported shell syntaxes.
+const (
+	Posix      = "posix"
+	Fish       = "fish"
+	PowerShell = "powershell"
+)
+
+// aliases maps accepted shell names to their syntax.
+var aliases = map[string]string{
+	"posix":      Posix,
+	"sh":         Posix,
+	"bash":       Posix,
+	"zsh":        Posix,
+	"fish":       Fish,
+	"powershell": PowerShell,
+	"pwsh":       PowerShell,
+}
+
+// Parse resolves a shell name to its syntax.
+func Parse(shell string) (string, error) {
+	syntax, ok := aliases[strings.ToLower(shell)]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell: %v", shell)
+	}
+
+	return syntax, nil
+}
+
+// Detect guesses the syntax of the user's shell from $SHELL, defaulting to PowerShell on Windows.
+func Detect() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		if syntax, err := Parse(filepath.Base(shell)); err == nil {
+			return syntax
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return PowerShell
+	}
+
+	return Posix
+}
+
+// Format renders the variables as statements that set them in the given shell syntax.
+func Format(syntax string, env []types.EnvVar) (string, error) {
+	var b strings.Builder
+
+	for _, variable := range env {
+		switch syntax {
+		case Posix:
+			fmt.Fprintf(&b, "export %s=%s\n", variable.Name, quotePosix(variable.Value))
+		case Fish:
+			fmt.Fprintf(&b, "set -gx %s %s\n", variable.Name, quoteFish(variable.Value))
+		case PowerShell:
+			fmt.Fprintf(&b, "$Env:%s = %s\n", variable.Name, quotePowerShell(variable.Value))
+		default:
+			return "", fmt.Errorf("unsupported shell: %v", syntax)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// quotePosix single-quotes a value, closing and reopening the quotes around embedded single quotes.
+func quotePosix(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish single-quotes a value, where only backslashes and single quotes need escaping.
+func quoteFish(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(escaped, "'", `\'`) + "'"
+}
+
+// quotePowerShell single-quotes a value, doubling embedded single quotes.
+func quotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
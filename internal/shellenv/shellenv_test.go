@@ -0,0 +1,83 @@
+package shellenv
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+func TestFormat(t *testing.T) {
+	env := []types.EnvVar{
+		{Name: "BAMBU_TOKEN", Value: `a'b\c $d`},
+		{Name: "BAMBU_REGION", Value: "us"},
+	}
+
+	tests := []struct {
+		syntax   string
+		expected string
+	}{
+		{
+			syntax:   Posix,
+			expected: "export BAMBU_TOKEN='a'\\''b\\c $d'\nexport BAMBU_REGION='us'\n",
+		},
+		{
+			syntax:   Fish,
+			expected: "set -gx BAMBU_TOKEN 'a\\'b\\\\c $d'\nset -gx BAMBU_REGION 'us'\n",
+		},
+		{
+			syntax:   PowerShell,
+			expected: "$Env:BAMBU_TOKEN = 'a''b\\c $d'\n$Env:BAMBU_REGION = 'us'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.syntax, func(t *testing.T) {
+			got, err := Format(tt.syntax, env)
+			if err != nil {
+				t.Fatalf("Format() unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Format() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := Format("cmd", env); err == nil {
+		t.Error("Format() expected an error for an unsupported shell")
+	}
+}
+
+func TestFormatPosixRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	value := `it's "quoted" \ $HOME ` + "`cmd`"
+	script, err := Format(Posix, []types.EnvVar{{Name: "VALUE", Value: value}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("sh", "-c", script+`printf '%s' "$VALUE"`).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != value {
+		t.Errorf("shell evaluated %q, expected %q", out, value)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]string{"bash": Posix, "ZSH": Posix, "fish": Fish, "pwsh": PowerShell} {
+		got, err := Parse(name)
+		if err != nil || got != expected {
+			t.Errorf("Parse(%q) = %q, %v, expected %q", name, got, err, expected)
+		}
+	}
+
+	if _, err := Parse("tcsh"); err == nil {
+		t.Error("Parse() expected an error for an unsupported shell")
+	}
+}
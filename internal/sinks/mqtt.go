@@ -0,0 +1,204 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+)
+
+// MQTT 3.1.1 control packet types used by the sink.
+const (
+	mqttConnect    byte = 0x10
+	mqttConnack    byte = 0x20
+	mqttPublish    byte = 0x30
+	mqttDisconnect byte = 0xe0
+	mqttRetainFlag byte = 0x01
+)
+
+// mqttSink publishes the tokens to a topic on an MQTT broker with QoS 0.
+type mqttSink struct {
+	url    *url.URL
+	topic  string
+	retain bool
+}
+
+func parseMQTTSink(spec string) (Sink, error) {
+	parsed, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt sink: %v", err)
+	}
+
+	topic := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Host == "" || topic == "" {
+		return nil, fmt.Errorf("mqtt sink needs a host and a topic: %v", parsed.Redacted())
+	}
+
+	return mqttSink{
+		url:    parsed,
+		topic:  topic,
+		retain: parsed.Query().Get("retain") == "true",
+	}, nil
+}
+
+func (s mqttSink) Name() string {
+	return s.url.Redacted()
+}
+
+func (s mqttSink) Write(tokens types.LoginResponse) error {
+	payload, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to mqtt broker: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := s.connect(conn); err != nil {
+		return err
+	}
+
+	flags := byte(0)
+	if s.retain {
+		flags |= mqttRetainFlag
+	}
+	publish := append(mqttString(s.topic), payload...)
+	if err := writeMQTTPacket(conn, mqttPublish|flags, publish); err != nil {
+		return fmt.Errorf("failed to publish: %v", err)
+	}
+
+	return writeMQTTPacket(conn, mqttDisconnect, nil)
+}
+
+func (s mqttSink) dial() (net.Conn, error) {
+	host := s.url.Host
+	if s.url.Port() == "" {
+		if s.url.Scheme == "mqtts" {
+			host = net.JoinHostPort(host, "8883")
+		} else {
+			host = net.JoinHostPort(host, "1883")
+		}
+	}
+
+	conn, err := httpclient.Dial(host, timeout)
+	if err != nil || s.url.Scheme != "mqtts" {
+		return conn, err
+	}
+
+	config := httpclient.TLSConfig()
+	config.ServerName = s.url.Hostname()
+	tlsConn := tls.Client(conn, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// connect sends CONNECT with a clean session and waits for a successful CONNACK.
+func (s mqttSink) connect(conn net.Conn) error {
+	flags := byte(0x02) // clean session
+	var credentials []byte
+	if s.url.User != nil {
+		flags |= 0x80
+		credentials = append(credentials, mqttString(s.url.User.Username())...)
+		if password, ok := s.url.User.Password(); ok {
+			flags |= 0x40
+			credentials = append(credentials, mqttString(password)...)
+		}
+	}
+
+	body := mqttString("MQTT")
+	body = append(body, 0x04, flags, 0x00, 0x3c) // protocol level 4, keep alive 60s
+	body = append(body, mqttString(fmt.Sprintf("bambulab-authenticator-%d", time.Now().UnixNano()))...)
+	body = append(body, credentials...)
+
+	if err := writeMQTTPacket(conn, mqttConnect, body); err != nil {
+		return fmt.Errorf("failed to send connect: %v", err)
+	}
+
+	header, ack, err := readMQTTPacket(bufio.NewReader(conn))
+	if err != nil {
+		return fmt.Errorf("failed to read connack: %v", err)
+	}
+	if header&0xf0 != mqttConnack || len(ack) != 2 {
+		return errors.New("unexpected response to connect")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("broker refused connection with code %d", ack[1])
+	}
+
+	return nil
+}
+
+func mqttString(value string) []byte {
+	encoded := make([]byte, 2, 2+len(value))
+	binary.BigEndian.PutUint16(encoded, uint16(len(value)))
+	return append(encoded, value...)
+}
+
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+
+	// Remaining length, variable length encoding
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
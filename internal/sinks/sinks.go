@@ -0,0 +1,238 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/bambulab-authenticator/internal/httpclient"
+	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"github.com/ondrovic/bambulab-authenticator/internal/utils"
+)
+
+// timeout bounds how long a network sink may take.
+const timeout = 15 * time.Second
+
+// Sink is a destination the saved tokens are delivered to.
+type Sink interface {
+	// Name identifies the sink in reports, without secrets.
+	Name() string
+	// Write delivers the tokens.
+	Write(tokens types.LoginResponse) error
+}
+
+// Report is the outcome of delivering to one sink.
+type Report struct {
+	Sink  string `json:"sink"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Parse builds a sink from its spec:
+//
+//	stdout
+//	file:<dir>                         writes <dir>/auth.json
+//	webhook:<http(s) url>              POSTs the tokens as JSON
+//	mqtt://[user:pass@]host[:port]/<topic>[?retain=true]   (mqtts:// for TLS)
+//	exec:<command>                     runs the command with the tokens as JSON on stdin
+func Parse(spec string) (Sink, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+
+	switch kind {
+	case "stdout":
+		return stdoutSink{out: os.Stdout}, nil
+	case "file":
+		if target == "" {
+			return nil, fmt.Errorf("file sink needs a directory: %v", spec)
+		}
+		return fileSink{dir: target}, nil
+	case "webhook":
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("webhook sink needs an http(s) url: %v", spec)
+		}
+		return webhookSink{url: parsed}, nil
+	case "mqtt", "mqtts":
+		return parseMQTTSink(spec)
+	case "exec":
+		if target == "" {
+			return nil, fmt.Errorf("exec sink needs a command: %v", spec)
+		}
+		return execSink{command: target}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink: %v", spec)
+	}
+}
+
+// ParseAll parses every spec, failing on the first invalid one so nothing is delivered half-configured.
+func ParseAll(specs []string) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(specs))
+	for _, spec := range specs {
+		sink, err := Parse(spec)
+		if err != nil {
+			return nil, types.NewCodedError(types.ErrCodeInvalidArgument, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// Deliver writes the tokens to every sink. A failing sink does not stop the others;
+// each outcome is reported.
+func Deliver(sinks []Sink, tokens types.LoginResponse) []Report {
+	reports := make([]Report, 0, len(sinks))
+	for _, sink := range sinks {
+		report := Report{Sink: sink.Name()}
+		if err := sink.Write(tokens); err != nil {
+			report.Error = err.Error()
+		} else if file, ok := sink.(fileSink); ok {
+			report.Path = utils.AuthFilePath(file.dir)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// Failed returns the reports of sinks that could not be written.
+func Failed(reports []Report) []Report {
+	var failed []Report
+	for _, report := range reports {
+		if report.Error != "" {
+			failed = append(failed, report)
+		}
+	}
+
+	return failed
+}
+
+func marshalTokens(tokens types.LoginResponse) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tokens: %v", err)
+	}
+
+	return jsonData, nil
+}
+
+type stdoutSink struct {
+	out io.Writer
+}
+
+func (s stdoutSink) Name() string {
+	return "stdout"
+}
+
+func (s stdoutSink) Write(tokens types.LoginResponse) error {
+	jsonData, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(s.out, string(jsonData))
+	return err
+}
+
+type fileSink struct {
+	dir string
+}
+
+func (s fileSink) Name() string {
+	return "file:" + s.dir
+}
+
+func (s fileSink) Write(tokens types.LoginResponse) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	return utils.SaveLoginResponseToFile(tokens, s.dir)
+}
+
+type webhookSink struct {
+	url *url.URL
+}
+
+func (s webhookSink) Name() string {
+	return "webhook:" + redactedURL(s.url)
+}
+
+func (s webhookSink) Write(tokens types.LoginResponse) error {
+	jsonData, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout, Transport: httpclient.Transport()}
+	resp, err := client.Post(s.url.String(), "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook request failed with status: %v", resp.Status)
+	}
+
+	return nil
+}
+
+type execSink struct {
+	command string
+}
+
+// Name shows only the program, the arguments and any leading VAR=value assignments may hold secrets.
+func (s execSink) Name() string {
+	return "exec:" + redactedCommand(s.command)
+}
+
+func (s execSink) Write(tokens types.LoginResponse) error {
+	jsonData, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	cmd := utils.ShellCommand(s.command)
+	cmd.Stdin = bytes.NewReader(jsonData)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command failed: %v", err)
+	}
+
+	return nil
+}
+
+// redactedCommand shortens a shell command to the program it runs, marking left out parts with "...".
+func redactedCommand(command string) string {
+	fields := strings.Fields(command)
+	for i, field := range fields {
+		if strings.Contains(field, "=") {
+			continue
+		}
+		if i == 0 && len(fields) == 1 {
+			return field
+		}
+		return field + " ..."
+	}
+
+	return "..."
+}
+
+// redactedURL hides the password and the query string, which often carries a webhook secret.
+func redactedURL(u *url.URL) string {
+	clone := *u
+	if clone.RawQuery != "" {
+		clone.RawQuery = "REDACTED"
+	}
+
+	return clone.Redacted()
+}
@@ -0,0 +1,45 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	// RFC 6238 appendix B SHA1 test vectors, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name     string
+		secret   string
+		time     int64
+		expected string
+	}{
+		{name: "59", secret: secret, time: 59, expected: "287082"},
+		{name: "1111111109", secret: secret, time: 1111111109, expected: "081804"},
+		{name: "1234567890", secret: secret, time: 1234567890, expected: "005924"},
+		{name: "2000000000", secret: secret, time: 2000000000, expected: "279037"},
+		{name: "Lowercase with spaces", secret: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time: 59, expected: "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Generate(tt.secret, time.Unix(tt.time, 0))
+			if err != nil {
+				t.Fatalf("Generate() unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Generate() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "not base32!"} {
+		if _, err := Generate(secret, time.Unix(59, 0)); err == nil {
+			t.Errorf("Generate(%q) expected an error", secret)
+		}
+	}
+}
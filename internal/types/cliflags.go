@@ -1,8 +1,77 @@
 package types
 
+import "time"
+
 type CliFlags struct {
-	OutputPath   string
-	UserAccount  string
-	UserPassword string
-	UserRegion   string
+	BaseURL           string
+	CAFiles           []string
+	ClientCert        string
+	ClientKey         string
+	Code              string
+	CodeAttempts      int
+	CodeCommand       string
+	CredentialHelper  string
+	Force             bool
+	HARPath           string
+	HeaderProfile     string
+	HeaderProfileFile string
+	HistorySize       int
+	Insecure          bool
+	LogFormat         string
+	LogLevel          string
+	Method            string
+	MinTLSVersion     string
+	NonInteractive    bool
+	Output            string
+	OutputPath        string
+	PasswordCommand   string
+	Proxy             string
+	Refresh           bool
+	ResendCooldown    time.Duration
+	SaveCookies       bool
+	Shell             string
+	Sinks             []string
+	StateDir          string
+	TOTPSecret        string
+	Trace             bool
+	UserAccount       string
+	UserPassword      string
+	UserRegion        string
+	Verbose           bool
+}
+
+type MockServerFlags struct {
+	Listen       string
+	Scenario     string
+	Account      string
+	Password     string
+	Code         string
+	RejectCodes  int
+	CodeCooldown time.Duration
+	OmitTokens   bool
+}
+
+type ExportFlags struct {
+	File              string
+	KustomizeDir      string
+	Labels            map[string]string
+	Name              string
+	Namespace         string
+	Prefix            string
+	SealedSecretScope string
+	SecretsFile       string
+}
+
+type InspectFlags struct {
+	ShowToken bool
+	Stdin     bool
+}
+
+type CheckFlags struct {
+	Critical        time.Duration
+	Prometheus      string
+	RefreshCritical time.Duration
+	RefreshWarning  time.Duration
+	SkipProfile     bool
+	Warning         time.Duration
 }
@@ -0,0 +1,43 @@
+package types
+
+import "errors"
+
+// Error codes reported in machine-readable output. They are part of the stable output schema.
+const (
+	ErrCodeUnknown         = "unknown"
+	ErrCodeCodeRequired    = "code_required"
+	ErrCodeInvalidArgument = "invalid_argument"
+	ErrCodeLoginFailed     = "login_failed"
+	ErrCodeRateLimited     = "rate_limited"
+	ErrCodeSessionInvalid  = "session_invalid"
+	ErrCodeSinkFailed      = "sink_failed"
+)
+
+// CodedError attaches a stable error code to an error.
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+// NewCodedError wraps err with the given code.
+func NewCodedError(code string, err error) *CodedError {
+	return &CodedError{Code: code, Err: err}
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the code of the first CodedError in err's chain, or ErrCodeUnknown.
+func ErrorCode(err error) string {
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+
+	return ErrCodeUnknown
+}
@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// StampExpiry converts the relative ExpiresIn and RefreshExpiresIn values (seconds)
+// into absolute unix timestamps measured from now, so a saved response can tell
+// when it stops being valid.
+func (r *LoginResponse) StampExpiry(now time.Time) {
+	if r.ExpiresIn > 0 {
+		r.ExpiresAt = now.Add(time.Duration(r.ExpiresIn) * time.Second).Unix()
+	}
+	if r.RefreshExpiresIn > 0 {
+		r.RefreshExpiresAt = now.Add(time.Duration(r.RefreshExpiresIn) * time.Second).Unix()
+	}
+}
+
+// ExpiresWithin reports whether the access token expires before now+window.
+// Responses without an absolute expiry are treated as not expiring.
+func (r *LoginResponse) ExpiresWithin(now time.Time, window time.Duration) bool {
+	if r.ExpiresAt == 0 {
+		return false
+	}
+	return !now.Add(window).Before(time.Unix(r.ExpiresAt, 0))
+}
+
+// CanRefresh reports whether the response holds a refresh token that has not expired.
+func (r *LoginResponse) CanRefresh(now time.Time) bool {
+	if r.RefreshToken == "" {
+		return false
+	}
+	return r.RefreshExpiresAt == 0 || now.Before(time.Unix(r.RefreshExpiresAt, 0))
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStampExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	resp := LoginResponse{ExpiresIn: 3600, RefreshExpiresIn: 7200}
+
+	resp.StampExpiry(now)
+
+	if resp.ExpiresAt != now.Unix()+3600 {
+		t.Errorf("ExpiresAt = %d, expected %d", resp.ExpiresAt, now.Unix()+3600)
+	}
+	if resp.RefreshExpiresAt != now.Unix()+7200 {
+		t.Errorf("RefreshExpiresAt = %d, expected %d", resp.RefreshExpiresAt, now.Unix()+7200)
+	}
+}
+
+func TestExpiresWithin(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		resp     LoginResponse
+		window   time.Duration
+		expected bool
+	}{
+		{
+			name:     "No expiry recorded",
+			resp:     LoginResponse{},
+			window:   time.Minute,
+			expected: false,
+		},
+		{
+			name:     "Expires outside window",
+			resp:     LoginResponse{ExpiresAt: now.Add(time.Hour).Unix()},
+			window:   time.Minute,
+			expected: false,
+		},
+		{
+			name:     "Expires inside window",
+			resp:     LoginResponse{ExpiresAt: now.Add(30 * time.Second).Unix()},
+			window:   time.Minute,
+			expected: true,
+		},
+		{
+			name:     "Already expired",
+			resp:     LoginResponse{ExpiresAt: now.Add(-time.Hour).Unix()},
+			window:   0,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resp.ExpiresWithin(now, tt.window); got != tt.expected {
+				t.Errorf("ExpiresWithin() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanRefresh(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		resp     LoginResponse
+		expected bool
+	}{
+		{
+			name:     "No refresh token",
+			resp:     LoginResponse{},
+			expected: false,
+		},
+		{
+			name:     "Refresh token without expiry",
+			resp:     LoginResponse{RefreshToken: "refresh"},
+			expected: true,
+		},
+		{
+			name:     "Refresh token expired",
+			resp:     LoginResponse{RefreshToken: "refresh", RefreshExpiresAt: now.Add(-time.Second).Unix()},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resp.CanRefresh(now); got != tt.expected {
+				t.Errorf("CanRefresh() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "encoding/json"
+
 type LoginPayload struct {
 	Account  string `json:"account"`
 	Password string `json:"password"`
@@ -16,11 +18,20 @@ type RequestEmailCodePayload struct {
 	Type  string `json:"type"`
 }
 
+type RequestSMSCodePayload struct {
+	Phone string `json:"phone"`
+	Type  string `json:"type"`
+}
+
 type EmailCodePayload struct {
 	Account string `json:"account"`
 	Code    string `json:"code"`
 }
 
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type LoginResponse struct {
 	AccessToken      string `json:"accessToken,omitempty"`
 	RefreshToken     string `json:"refreshToken,omitempty"`
@@ -29,4 +40,35 @@ type LoginResponse struct {
 	TfaKey           string `json:"tfaKey,omitempty"`
 	AccessMethod     string `json:"accessMethod,omitempty"`
 	LoginType        string `json:"loginType,omitempty"`
+	ExpiresAt        int64  `json:"expiresAt,omitempty"`
+	RefreshExpiresAt int64  `json:"refreshExpiresAt,omitempty"`
+	Account          string `json:"account,omitempty"`
+	Region           string `json:"region,omitempty"`
+}
+
+type Profile struct {
+	UID     json.Number `json:"uid"`
+	Account string      `json:"account,omitempty"`
+	Name    string      `json:"name,omitempty"`
+}
+
+// Device is a printer bound to the account.
+type Device struct {
+	Serial      string `json:"dev_id"`
+	Name        string `json:"name"`
+	Online      bool   `json:"online"`
+	Model       string `json:"dev_product_name,omitempty"`
+	AccessCode  string `json:"dev_access_code"`
+	PrintStatus string `json:"print_status,omitempty"`
+}
+
+// DevicesResponse is the body of the bind endpoint listing the account's printers.
+type DevicesResponse struct {
+	Devices []Device `json:"devices"`
+}
+
+// EnvVar is an environment variable handed to child processes or printed for a shell.
+type EnvVar struct {
+	Name  string
+	Value string
 }
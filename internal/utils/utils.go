@@ -3,34 +3,137 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"time"
 
 	"github.com/ondrovic/bambulab-authenticator/internal/types"
+	"golang.org/x/term"
 )
 
+// AuthFileName is the name of the file the login response is saved to inside the output path
+const AuthFileName = "auth.json"
+
+// CookieFileName is the name of the file the session cookies are saved to inside the output path
+const CookieFileName = "cookies.json"
+
 // IsEmpty checks if a string is empty
 func IsEmpty(s string) bool {
 	return s == ""
 }
 
+// ShellCommand builds an exec.Cmd running the command line through the platform shell
+func ShellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+
+	return exec.Command("sh", "-c", command)
+}
+
+// AuthFilePath returns the full path of the auth file inside the given output path
+func AuthFilePath(path string) string {
+	return filepath.Join(path, AuthFileName)
+}
+
+// CookieFilePath returns the full path of the cookie file inside the given output path
+func CookieFilePath(path string) string {
+	return filepath.Join(path, CookieFileName)
+}
+
 // SaveLoginResponseToFile serializes the LoginResponse struct to JSON and saves it to the given file path
 func SaveLoginResponseToFile(loginResponse types.LoginResponse, path string) error {
 
-	fullPath := filepath.Join(path, "auth.json")
+	fullPath := AuthFilePath(path)
+
+	// Record absolute expiry times so later runs know when to refresh
+	if loginResponse.ExpiresAt == 0 && loginResponse.RefreshExpiresAt == 0 {
+		loginResponse.StampExpiry(time.Now())
+	}
+
 	// Marshal the struct to JSON (with indentation for readability)
 	jsonData, err := json.MarshalIndent(loginResponse, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %v", err)
 	}
 
-	// Write the JSON data to the file
-	err = os.WriteFile(fullPath, jsonData, 0644) // 0644 is the file permission mode
+	// An interrupted save never leaves a truncated auth file
+	if err := WriteFileAtomic(fullPath, jsonData, 0644); err != nil { // 0644 is the file permission mode
+		return err
+	}
+
+	slog.Debug("auth data saved", "path", fullPath)
+
+	return nil
+}
+
+// WriteFileAtomic replaces the file at path with data through a temporary file in the same directory,
+// so readers see either the old or the new content
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
 	if err != nil {
 		return fmt.Errorf("failed to write to file: %v", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	fmt.Printf("Auth data saved to %s", fullPath)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
 
 	return nil
 }
+
+// Prompt asks the user for a value on stderr, keeping stdout free for results, and reads the answer from stdin
+func Prompt(message string) string {
+	fmt.Fprint(os.Stderr, message)
+
+	var answer string
+	fmt.Scanln(&answer)
+
+	return answer
+}
+
+// PromptSecret asks the user for a secret on stderr and reads it from the terminal without echoing it
+func PromptSecret(message string) (string, error) {
+	fmt.Fprint(os.Stderr, message)
+	defer fmt.Fprintln(os.Stderr)
+
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %v", err)
+	}
+
+	return string(secret), nil
+}
+
+// LoadLoginResponseFromFile reads the auth file from the given path and deserializes it into a LoginResponse
+func LoadLoginResponseFromFile(path string) (*types.LoginResponse, error) {
+
+	fullPath := AuthFilePath(path)
+
+	jsonData, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	var loginResponse types.LoginResponse
+	if err := json.Unmarshal(jsonData, &loginResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %v", err)
+	}
+
+	return &loginResponse, nil
+}
@@ -131,3 +131,36 @@ func TestSaveLoginResponseToFile(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadLoginResponseFromFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "login-response-load-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	saved := types.LoginResponse{
+		AccessToken:      "abc123",
+		RefreshToken:     "def456",
+		ExpiresIn:        3600,
+		RefreshExpiresIn: 7200,
+	}
+	if err := SaveLoginResponseToFile(saved, tempDir); err != nil {
+		t.Fatalf("failed to save login response: %v", err)
+	}
+
+	loaded, err := LoadLoginResponseFromFile(tempDir)
+	if err != nil {
+		t.Fatalf("LoadLoginResponseFromFile() unexpected error: %v", err)
+	}
+	if loaded.AccessToken != saved.AccessToken || loaded.RefreshToken != saved.RefreshToken {
+		t.Errorf("LoadLoginResponseFromFile() = %+v, expected tokens from %+v", loaded, saved)
+	}
+	if loaded.ExpiresAt == 0 || loaded.RefreshExpiresAt == 0 {
+		t.Errorf("LoadLoginResponseFromFile() expected expiry timestamps to be stamped, got %+v", loaded)
+	}
+
+	if _, err := LoadLoginResponseFromFile("/this/path/does/not/exist"); !containsErrorMessage(err, "failed to read file:") {
+		t.Errorf("LoadLoginResponseFromFile() error = %v, expected read failure", err)
+	}
+}